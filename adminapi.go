@@ -1,27 +1,26 @@
 package radosgwadmin
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/google/go-querystring/query"
 	"github.com/myENA/restclient"
 	"github.com/smartystreets/go-aws-auth"
 )
 
-var tz *time.Location
+var falsch, wahr = false, true
 
-var falsch = false
-
-func init() {
-	tz, _ = time.LoadLocation("Local") // Defaults to local
-}
-
-// SetTimeZone - override time zone.  Not thread-safe, do
-// this at initialization time or protect it with a mutex
-// if necessary.
+// SetTimeZone - override the default time zone that bucket format times are
+// decoded in, local time unless set.  AdminAPIs configured with a ZoneName are
+// not affected.
 func SetTimeZone(loc *time.Location) {
+	tzMu.Lock()
+	defer tzMu.Unlock()
 	tz = loc
 }
 
@@ -32,19 +31,41 @@ func SetTimeZone(loc *time.Location) {
 // boolean false value.
 var FalseRef = &falsch
 
+// TrueRef - like FalseRef, for optional bools that should be true.
+var TrueRef = &wahr
+
 // AdminAPI - admin api struct
 type AdminAPI struct {
 	*restclient.BaseClient
-	creds *awsauth.Credentials
+	creds       CredentialsProvider
+	signingMode SigningMode
+	v4          *v4Signer
+	endpoints   *endpointPool
+	retry       RetryPolicy
+
+	middleware       []Middleware
+	signedMiddleware []Middleware
+	tracer           Tracer
+	metrics          MetricsObserver
+	loc              *time.Location
+	plan             *Plan // set for dry runs
+	scanConcurrency  int
+	listPageSize     int
 }
 
+// DefaultScanConcurrency - default for Config.ScanConcurrency.
+const DefaultScanConcurrency = 8
+
 // NewAdminAPI - AdminAPI factory method.
 func NewAdminAPI(cfg *Config) (*AdminAPI, error) {
-	baseURL := strings.Trim(cfg.ServerURL, "/")
-	adminPath := strings.Trim(cfg.AdminPath, "/")
 	aa := &AdminAPI{}
 	var err error
-	u, err := url.Parse(baseURL + "/" + adminPath)
+	aa.endpoints, err = newEndpointPool(
+		append([]string{cfg.ServerURL}, cfg.ServerURLs...),
+		cfg.AdminPath,
+		cfg.EndpointSelection,
+		time.Duration(cfg.EjectDuration),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -53,32 +74,106 @@ func NewAdminAPI(cfg *Config) (*AdminAPI, error) {
 	if err != nil {
 		return nil, err
 	}
-	aa.BaseClient = &restclient.BaseClient{Client: c, BaseURL: u}
-	aa.Client.FixupCallback = aa.fixupCallback
+	aa.retry = cfg.Retry.withDefaults()
+	aa.tracer = cfg.Tracer
+	if aa.tracer == nil {
+		aa.tracer = NoopTracer{}
+	}
+	aa.metrics = cfg.Metrics
+	aa.scanConcurrency = cfg.ScanConcurrency
+	if aa.scanConcurrency <= 0 {
+		aa.scanConcurrency = DefaultScanConcurrency
+	}
+	aa.listPageSize = cfg.ListPageSize
+	if aa.listPageSize <= 0 {
+		aa.listPageSize = DefaultListPageSize
+	}
+
+	c.Client.Transport = &adminTransport{aa: aa, base: c.Client.Transport}
+	aa.BaseClient = &restclient.BaseClient{Client: c, BaseURL: aa.endpoints.primary()}
+	aa.Client.ErrorResponseCallback = decodeErrorResponse
+	// Requests are validated by call, with errors of our own type.
+	aa.Client.SkipValidate = true
 
-	aa.creds = &awsauth.Credentials{
-		AccessKeyID:     cfg.AccessKeyID,
-		SecretAccessKey: cfg.SecretAccessKey,
-		SecurityToken:   cfg.SecurityToken,
-		Expiration:      cfg.Expiration,
+	aa.creds = cfg.CredentialsProvider
+	if aa.creds == nil {
+		aa.creds = StaticCredentials{
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+			SecurityToken:   cfg.SecurityToken,
+			Expiration:      cfg.Expiration,
+		}
 	}
 
-	if cfg.ZoneName != "" && tz.String() != cfg.ZoneName {
-		tz, err = time.LoadLocation(cfg.ZoneName)
+	switch cfg.SigningMode {
+	case "", SigningModeV2:
+		aa.signingMode = SigningModeV2
+	case SigningModeV4:
+		aa.signingMode = SigningModeV4
+		aa.v4 = newV4Signer(cfg.SigningRegion, cfg.SigningService)
+	default:
+		return nil, fmt.Errorf("unknown signing mode %q", cfg.SigningMode)
 	}
 
-	if err != nil {
-		return nil, err
+	if cfg.ZoneName != "" {
+		aa.loc, err = time.LoadLocation(cfg.ZoneName)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return aa, nil
 }
 
+// Location - the time zone bucket format times are decoded in, for the mtime
+// of BucketStatsResponse and the metadata responses.  This is Config.ZoneName,
+// or the SetTimeZone default if that is not set.
+func (aa *AdminAPI) Location() *time.Location {
+	if aa.loc != nil {
+		return aa.loc
+	}
+	return defaultLocation()
+}
+
 // Config - this configures an AdminAPI.
 //
 // Specify CACertBundlePath to load a bundle from disk to override the default.
 // Specify CACertBundle if you want embed the cacert bundle in PEM format.
 // Specify one or the other.  If both are specified, CACertBundle is honored.
+//
+// ZoneName is the time zone the gateway reports bucket times in, as accepted by
+// time.LoadLocation.  It applies to this AdminAPI only, and defaults to the
+// zone set with SetTimeZone.
+//
+// SigningMode selects between AWS V2 (the default) and V4 signatures.  For V4,
+// SigningRegion and SigningService default to DefaultSigningRegion and
+// DefaultSigningService respectively.
+//
+// CredentialsProvider, if set, is asked for credentials before every request
+// and AccessKeyID, SecretAccessKey, SecurityToken and Expiration are ignored.
+// Otherwise those fields are used as static credentials.
+//
+// To spread requests across several gateways, list them in ServerURLs (ServerURL,
+// if set, is used as the first of them).  EndpointSelection picks between
+// round-robin, the default, and priority order.  A gateway that fails with a
+// connection error or a 5xx response is skipped for EjectDuration, which defaults
+// to DefaultEjectDuration, and idempotent requests are retried on the next one.
+// All gateways share the same AdminPath.
+//
+// Retry controls retries of failed requests, see RetryPolicy.
+//
+// Tracer, if set, receives a span for every operation, see Tracer.
+//
+// Metrics, if set, is told about the outcome and duration of every operation,
+// see MetricsObserver.
+//
+// ScanConcurrency limits the requests in flight when a lookup has to fall back
+// to scanning every user, as UserInfoByEmail may.  It defaults to
+// DefaultScanConcurrency.
+//
+// ListPageSize is the number of entries fetched at a time by the listing
+// iterators, Users, Buckets and BucketInstances.  It defaults to
+// DefaultListPageSize.
 type Config struct {
 	restclient.ClientConfig
 	ServerURL        string
@@ -86,18 +181,105 @@ type Config struct {
 	CACertBundlePath string
 	ZoneName         string
 	AccessKeyID      string
-	SecretAccessKey  string
-	SecurityToken    string
+	SecretAccessKey  Secret
+	SecurityToken    Secret
 	Expiration       time.Time
+	SigningMode      SigningMode
+	SigningRegion    string
+	SigningService   string
+
+	ServerURLs        []string
+	EndpointSelection EndpointSelection
+	EjectDuration     restclient.Duration
+	Retry             RetryPolicy
+	ScanConcurrency   int
+	ListPageSize      int
+
+	CredentialsProvider CredentialsProvider `toml:"-" json:"-"`
+	Tracer              Tracer              `toml:"-" json:"-"`
+	Metrics             MetricsObserver     `toml:"-" json:"-"`
 }
 
-func (aa *AdminAPI) fixupCallback(req *http.Request) error {
+// sign - sign req with the current credentials.  This is called by the transport
+// once the endpoint for the request is known.
+func (aa *AdminAPI) sign(req *http.Request) error {
 	req.URL.Query().Set("format", "json")
 
-	_ = awsauth.SignS3(req, *aa.creds)
-
 	// This is to appease AWS signature algorithm.  spaces must
 	// be %20, go defaults to +
 	req.URL.RawQuery = strings.Replace(req.URL.RawQuery, "+", "%20", -1)
+
+	c, err := aa.creds.Retrieve(req.Context())
+	if err != nil {
+		return err
+	}
+	creds := awsauth.Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey.Reveal(),
+		SecurityToken:   c.SecurityToken.Reveal(),
+		Expiration:      c.Expiration,
+	}
+
+	if aa.signingMode == SigningModeV4 {
+		return aa.v4.sign(req, creds)
+	}
+	if err = setContentMD5(req); err != nil {
+		return err
+	}
+	_ = awsauth.SignS3(req, creds)
 	return nil
 }
+
+// get, put, post, delete - every admin operation goes through one of these, or
+// putBody.  op is the name of the public method, used for tracing and metrics.
+func (aa *AdminAPI) get(ctx context.Context, op, path string, queryStruct, responseBody interface{}) error {
+	return aa.call(ctx, op, http.MethodGet, path, queryStruct, nil, responseBody)
+}
+
+func (aa *AdminAPI) put(ctx context.Context, op, path string, queryStruct, responseBody interface{}) error {
+	return aa.call(ctx, op, http.MethodPut, path, queryStruct, nil, responseBody)
+}
+
+func (aa *AdminAPI) post(ctx context.Context, op, path string, queryStruct, responseBody interface{}) error {
+	return aa.call(ctx, op, http.MethodPost, path, queryStruct, nil, responseBody)
+}
+
+func (aa *AdminAPI) delete(ctx context.Context, op, path string, queryStruct, responseBody interface{}) error {
+	return aa.call(ctx, op, http.MethodDelete, path, queryStruct, nil, responseBody)
+}
+
+// putBody - put with a request body, which is sent as json.
+func (aa *AdminAPI) putBody(ctx context.Context, op, path string, queryStruct, requestBody, responseBody interface{}) error {
+	return aa.call(ctx, op, http.MethodPut, path, queryStruct, requestBody, responseBody)
+}
+
+func (aa *AdminAPI) call(ctx context.Context, op, method, path string, queryStruct, requestBody, responseBody interface{}) error {
+	start := time.Now()
+	ctx, ci := withCallInfo(ctx, op)
+	ctx, span := aa.tracer.Start(ctx, "radosgwadmin."+op)
+	defer span.End()
+
+	var q url.Values
+	if queryStruct != nil {
+		q, _ = query.Values(queryStruct)
+	}
+	setRequestAttributes(span, op, method, path, q)
+
+	err := validateRequest(op, queryStruct)
+	// Planned calls are not sent, so there is nothing for metrics to observe.
+	if err == nil && aa.plan != nil && mutates(method, queryStruct) {
+		return aa.planCall(ctx, op, method, path, q, queryStruct, responseBody)
+	}
+	if err == nil {
+		var body interface{}
+		if responseBody != nil {
+			body = &locDecoder{v: responseBody, loc: aa.Location()}
+		}
+		_, err = aa.Req(ctx, method, path, queryStruct, requestBody, body)
+	}
+	setResultAttributes(span, ci, err)
+	if aa.metrics != nil {
+		aa.metrics.ObserveCall(newCallObservation(ci, method, time.Since(start), err))
+	}
+	return err
+}
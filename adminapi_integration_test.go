@@ -90,7 +90,7 @@ func (is *IntegrationsSuite) Test01Usage() {
 	usage, err := is.aa.Usage(context.Background(), nil)
 	is.NoError(err, "Got error getting Usage")
 	is.T().Logf("usage: %#v", usage)
-	err = is.aa.UsageTrim(context.Background(), &TrimUsageRequest{UID: is.ic.Integration.TestUID})
+	err = is.aa.UsageTrim(context.Background(), &TrimUsageRequest{UID: UserID{ID: is.ic.Integration.TestUID}})
 	is.NoError(err, "Got error trimming usage")
 }
 
@@ -102,7 +102,7 @@ func (is *IntegrationsSuite) Test02Metadata() {
 
 func (is *IntegrationsSuite) Test03UserCreate() {
 	ur := new(UserCreateRequest)
-	ur.UID = is.ic.Integration.TestUID
+	ur.UID = UserID{ID: is.ic.Integration.TestUID}
 	ur.Email = is.ic.Integration.TestEmail
 	ur.DisplayName = is.ic.Integration.TestDisplayName
 	ur.UserCaps = []UserCap{{"users", "*"}, {"metadata", "*"}, {"buckets", "read"}}
@@ -111,7 +111,7 @@ func (is *IntegrationsSuite) Test03UserCreate() {
 	is.NoError(err, "Got error running UserCreate")
 	is.T().Logf("%#v", resp)
 	sur := new(SubUserCreateModifyRequest)
-	sur.UID = is.ic.Integration.TestUID
+	sur.UID = UserID{ID: is.ic.Integration.TestUID}
 	sur.Access = "full"
 	sur.KeyType = "s3"
 	sur.SubUser = is.ic.Integration.TestSubUser
@@ -132,11 +132,11 @@ func (is *IntegrationsSuite) Test04Quota() {
 	qsr.MaximumObjects = -1 // unlimited
 	qsr.MaximumSizeKb = 61440
 	qsr.QuotaType = "user"
-	qsr.UID = is.ic.Integration.TestUID
+	qsr.UID = UserID{ID: is.ic.Integration.TestUID}
 	err := is.aa.QuotaSet(context.Background(), qsr)
 	is.NoError(err, "Got error running SetQuota")
 	// read it back
-	qresp, err := is.aa.QuotaUser(context.Background(), is.ic.Integration.TestUID)
+	qresp, err := is.aa.QuotaUser(context.Background(), UserID{ID: is.ic.Integration.TestUID})
 	is.T().Logf("%#v", qresp)
 	is.NoError(err, "Got error fetching user quota")
 	is.True(qresp.Enabled == true, "quota not enabled")
@@ -153,16 +153,16 @@ func (is *IntegrationsSuite) Test05Bucket() {
 		Fix:          true,
 	})
 
-	bucketnames, err := is.aa.BucketList(context.Background(), "")
+	bucketnames, err := is.aa.BucketList(context.Background(), UserID{})
 	is.NoError(err, "Got error fetching bucket names")
 	is.T().Logf("bucket names: %#v\n", bucketnames)
 	// bucketstats with no filters
-	bucketstats, err := is.aa.BucketStats(context.Background(), "", "")
+	bucketstats, err := is.aa.BucketStats(context.Background(), UserID{}, BucketRef{})
 	is.NoError(err, "got error fetching bucket stats")
 	is.T().Log(spew.Sdump(bucketstats))
 
 	// bucketstats with bucket filter
-	bucketstatsf, err := is.aa.BucketStats(context.Background(), "", is.ic.Integration.TestBucket)
+	bucketstatsf, err := is.aa.BucketStats(context.Background(), UserID{}, BucketRef{Name: is.ic.Integration.TestBucket})
 	is.NoError(err, "got error fetching bucket stats filtered by bucket")
 	is.T().Log(spew.Sdump(bucketstatsf))
 
@@ -179,7 +179,7 @@ func (is *IntegrationsSuite) Test05Bucket() {
 
 	// see if we can now get stats.
 
-	ui, err := is.aa.UserInfo(context.Background(), is.ic.Integration.TestUID, true)
+	ui, err := is.aa.UserInfo(context.Background(), UserID{ID: is.ic.Integration.TestUID}, true)
 
 	is.NoError(err)
 	is.NotNil(ui.Stats)
@@ -189,7 +189,7 @@ func (is *IntegrationsSuite) Test05Bucket() {
 
 func (is *IntegrationsSuite) Test06Caps() {
 	ucr := &UserCapsRequest{
-		UID:      is.ic.Integration.TestUID,
+		UID:      UserID{ID: is.ic.Integration.TestUID},
 		UserCaps: []UserCap{{"usage", "read"}},
 	}
 	newcaps, err := is.aa.CapsAdd(context.Background(), ucr)
@@ -235,7 +235,7 @@ func (is *IntegrationsSuite) Test07Keys() {
 	secretKey := "TESTSECRETKEY"
 	generateKey := false
 	kc := &KeyCreateRequest{
-		UID:         is.ic.Integration.TestUID,
+		UID:         UserID{ID: is.ic.Integration.TestUID},
 		AccessKey:   accessKey,
 		SecretKey:   secretKey,
 		GenerateKey: &generateKey,
@@ -245,7 +245,7 @@ func (is *IntegrationsSuite) Test07Keys() {
 	is.NoError(err, "Unexpected error adding key")
 	found := false
 	for _, key := range userKeys {
-		if key.AccessKey == accessKey && key.SecretKey == secretKey {
+		if key.AccessKey == accessKey && key.SecretKey.Reveal() == secretKey {
 			found = true
 			break
 		}
@@ -261,7 +261,7 @@ func (is *IntegrationsSuite) Test07Keys() {
 	is.NoError(err1, "Unexpected error deleting key")
 
 	// check if the key was really deleted
-	userInfo, err2 := is.aa.UserInfo(context.Background(), is.ic.Integration.TestUID, false)
+	userInfo, err2 := is.aa.UserInfo(context.Background(), UserID{ID: is.ic.Integration.TestUID}, false)
 	is.NoError(err2, "Unexpected error reading user info")
 	found = false
 	for i := range userInfo.Keys {
@@ -282,7 +282,7 @@ func (is *IntegrationsSuite) Test08RmUser() {
 			return
 		}
 	}
-	err := is.aa.UserRm(context.Background(), is.ic.Integration.TestUID, true)
+	err := is.aa.UserRm(context.Background(), UserID{ID: is.ic.Integration.TestUID}, true)
 	is.NoError(err, "got error removing user")
 	users, err := is.aa.MListUsers(context.Background())
 	is.NoError(err, "got error listing users")
@@ -305,12 +305,12 @@ func (is *IntegrationsSuite) writeRandomFile(path string, sizekb int) {
 	)
 
 	// grab creds for subuser s3 user
-	ui, err = is.aa.UserInfo(context.Background(), is.ic.Integration.TestUID, false)
+	ui, err = is.aa.UserInfo(context.Background(), UserID{ID: is.ic.Integration.TestUID}, false)
 
 	is.NoError(err)
 	for _, k := range ui.Keys {
 		if k.User == is.ic.Integration.TestUID {
-			creds = credentials.NewStaticCredentials(k.AccessKey, k.SecretKey, "")
+			creds = credentials.NewStaticCredentials(k.AccessKey, k.SecretKey.Reveal(), "")
 			break
 		}
 	}
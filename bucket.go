@@ -15,34 +15,34 @@ type bucketRequest struct {
 
 // BucketIndexRequest - bucket index request struct
 type BucketIndexRequest struct {
-	Bucket       string `url:"bucket" validation:"required"`
+	Bucket       string `url:"bucket" validate:"required"`
 	CheckObjects bool   `url:"check-objects,omitempty"`
 	Fix          bool   `url:"fix,omitempty"`
 }
 
 type bucketRmRequest struct {
-	Bucket       string `url:"bucket" validation:"required"`
+	Bucket       string `url:"bucket" validate:"required"`
 	PurgeObjects bool   `url:"purge-objects"`
 }
 
 type bucketLinkRequest struct {
-	Bucket   string `url:"bucket" validation:"required"`
-	BucketID string `url:"bucket-id" validation:"required"`
-	UID      string `url:"uid" validation:"required"`
+	Bucket   string `url:"bucket" validate:"required"`
+	BucketID string `url:"bucket-id" validate:"required"`
+	UID      string `url:"uid" validate:"required"`
 }
 
 type bucketUnlinkRequest struct {
-	Bucket string `url:"bucket" validation:"required"`
-	UID    string `url:"uid" validation:"required"`
+	Bucket string `url:"bucket" validate:"required"`
+	UID    string `url:"uid" validate:"required"`
 }
 
 type bucketObjectRmRequest struct {
-	Bucket string `url:"bucket" validation:"required"`
-	Object string `url:"object" validation:"required"`
+	Bucket string `url:"bucket" validate:"required"`
+	Object string `url:"object" validate:"required"`
 }
 
 type bucketPolicyRequest struct {
-	Bucket string `url:"bucket" validation:"required"`
+	Bucket string `url:"bucket" validate:"required"`
 	Object string `url:"object,omitempty"`
 }
 
@@ -156,15 +156,16 @@ type BucketPolicyResponse struct {
 // BucketList -
 //
 // return a list of all bucket names, optionally filtered by
-// uid
-func (aa *AdminAPI) BucketList(ctx context.Context, uid string) ([]string, error) {
+// uid.  All bucket names come in one response, on large clusters use Buckets
+// instead.
+func (aa *AdminAPI) BucketList(ctx context.Context, uid UserID) ([]string, error) {
 	breq := &bucketRequest{
-		UID:    uid,
+		UID:    uid.String(),
 		Bucket: "",
 		Stats:  false,
 	}
 	resp := []string{}
-	err := aa.Get(ctx, "/bucket", breq, &resp)
+	err := aa.get(ctx, "BucketList", "/bucket", breq, &resp)
 	return resp, err
 }
 
@@ -172,59 +173,59 @@ func (aa *AdminAPI) BucketList(ctx context.Context, uid string) ([]string, error
 //
 // return a list of all bucket stats, optionally filtered by
 // uid and bucket name
-func (aa *AdminAPI) BucketStats(ctx context.Context, uid string, bucket string) ([]BucketStatsResponse, error) {
+func (aa *AdminAPI) BucketStats(ctx context.Context, uid UserID, bucket BucketRef) ([]BucketStatsResponse, error) {
 	resp := []BucketStatsResponse{}
 	breq := &bucketRequest{
 		Stats: true,
 	}
-	if bucket != "" {
-		breq.Bucket = bucket
+	if !bucket.IsZero() {
+		breq.Bucket = bucket.String()
 		respB := BucketStatsResponse{}
-		err := aa.Get(ctx, "/bucket", breq, &respB)
+		err := aa.get(ctx, "BucketStats", "/bucket", breq, &respB)
 		return append(resp, respB), err
 	}
 
-	breq.UID = uid
-	err := aa.Get(ctx, "/bucket", breq, &resp)
+	breq.UID = uid.String()
+	err := aa.get(ctx, "BucketStats", "/bucket", breq, &resp)
 	return resp, err
 }
 
 // BucketIndex - Bucket index operations.  Bucket name required.
 func (aa *AdminAPI) BucketIndex(ctx context.Context, bireq *BucketIndexRequest) (*BucketIndexResponse, error) {
 	resp := &BucketIndexResponse{}
-	err := aa.Get(ctx, "/bucket?index", bireq, resp)
+	err := aa.get(ctx, "BucketIndex", "/bucket?index", bireq, resp)
 	return resp, err
 }
 
 // BucketRm - remove a bucket.  bucket must be non-empty string.
-func (aa *AdminAPI) BucketRm(ctx context.Context, bucket string, purge bool) error {
-	req := &bucketRmRequest{Bucket: bucket, PurgeObjects: purge}
-	return aa.Delete(ctx, "/bucket", req, nil)
+func (aa *AdminAPI) BucketRm(ctx context.Context, bucket BucketRef, purge bool) error {
+	req := &bucketRmRequest{Bucket: bucket.String(), PurgeObjects: purge}
+	return aa.delete(ctx, "BucketRm", "/bucket", req, nil)
 }
 
 // BucketUnlink - unlink a bucket from a user.  All parameters required.
-func (aa *AdminAPI) BucketUnlink(ctx context.Context, bucket string, uid string) error {
-	req := &bucketUnlinkRequest{Bucket: bucket, UID: uid}
-	return aa.Post(ctx, "/bucket", req, nil, nil)
+func (aa *AdminAPI) BucketUnlink(ctx context.Context, bucket BucketRef, uid UserID) error {
+	req := &bucketUnlinkRequest{Bucket: bucket.String(), UID: uid.String()}
+	return aa.post(ctx, "BucketUnlink", "/bucket", req, nil)
 }
 
 // BucketLink - link a bucket to a user, removing any previous links.  All
 // parameters required.
-func (aa *AdminAPI) BucketLink(ctx context.Context, bucket, bucketID, uid string) error {
-	req := &bucketLinkRequest{Bucket: bucket, BucketID: bucketID, UID: uid}
-	return aa.Put(ctx, "/bucket", req, nil, nil)
+func (aa *AdminAPI) BucketLink(ctx context.Context, bucket BucketRef, bucketID string, uid UserID) error {
+	req := &bucketLinkRequest{Bucket: bucket.String(), BucketID: bucketID, UID: uid.String()}
+	return aa.put(ctx, "BucketLink", "/bucket", req, nil)
 }
 
 // BucketObjectRm - remove a bucket.  bucket must be non-empty string.
-func (aa *AdminAPI) BucketObjectRm(ctx context.Context, bucket, object string) error {
-	req := &bucketObjectRmRequest{Bucket: bucket, Object: object}
-	return aa.Delete(ctx, "/bucket?object", req, nil)
+func (aa *AdminAPI) BucketObjectRm(ctx context.Context, bucket BucketRef, object string) error {
+	req := &bucketObjectRmRequest{Bucket: bucket.String(), Object: object}
+	return aa.delete(ctx, "BucketObjectRm", "/bucket?object", req, nil)
 }
 
 // BucketPolicy - get a bucket policy.  bucket required, object is optional.
-func (aa *AdminAPI) BucketPolicy(ctx context.Context, bucket, object string) (*BucketPolicyResponse, error) {
-	req := bucketPolicyRequest{Bucket: bucket, Object: object}
+func (aa *AdminAPI) BucketPolicy(ctx context.Context, bucket BucketRef, object string) (*BucketPolicyResponse, error) {
+	req := &bucketPolicyRequest{Bucket: bucket.String(), Object: object}
 	resp := &BucketPolicyResponse{}
-	err := aa.Get(ctx, "/bucket?policy", req, resp)
+	err := aa.get(ctx, "BucketPolicy", "/bucket?policy", req, resp)
 	return resp, err
 }
@@ -0,0 +1,196 @@
+package radosgwadmin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CapPerm - the permission a cap grants, read, write or both.
+type CapPerm uint8
+
+// CapPerm bits.
+const (
+	CapRead CapPerm = 1 << iota
+	CapWrite
+
+	// CapAll - read and write, which the gateway reports as *.
+	CapAll = CapRead | CapWrite
+)
+
+// ParseCapPerm - parse a permission as the gateway accepts it: "read", "write",
+// "*", or a comma separated list such as "read,write".
+func ParseCapPerm(s string) (CapPerm, error) {
+	var p CapPerm
+	for _, f := range strings.Split(s, ",") {
+		switch strings.TrimSpace(f) {
+		case "read":
+			p |= CapRead
+		case "write":
+			p |= CapWrite
+		case "*":
+			p |= CapAll
+		default:
+			return 0, fmt.Errorf("radosgwadmin: bad cap permission %q", s)
+		}
+	}
+	return p, nil
+}
+
+// String - implements fmt.Stringer, the permission as the gateway reports it.
+func (p CapPerm) String() string {
+	switch p {
+	case CapRead:
+		return "read"
+	case CapWrite:
+		return "write"
+	case CapAll:
+		return "*"
+	}
+	return ""
+}
+
+// CapSet - a user's caps, the permission for each cap type.  Types without a
+// permission are left out.
+type CapSet map[string]CapPerm
+
+// ParseCaps - parse caps in the gateway's format, e.g.
+// "users=read,write;buckets=*".  Caps of the same type are combined.  Any type
+// is accepted, the gateway knows which it supports.
+func ParseCaps(s string) (CapSet, error) {
+	cs := CapSet{}
+	for _, c := range strings.Split(s, ";") {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		t, perm, ok := cutString(c, "=")
+		t = strings.TrimSpace(t)
+		if !ok || t == "" {
+			return nil, fmt.Errorf("radosgwadmin: bad cap %q", c)
+		}
+		p, err := ParseCapPerm(perm)
+		if err != nil {
+			return nil, err
+		}
+		cs[t] |= p
+	}
+	return cs, nil
+}
+
+// NewCapSet - the caps as a CapSet, e.g. those of a UserInfoResponse.  Like
+// ParseCaps, it accepts any type.
+func NewCapSet(caps []UserCap) (CapSet, error) {
+	cs := CapSet{}
+	for _, c := range caps {
+		if c.Type == "" {
+			return nil, fmt.Errorf("radosgwadmin: bad cap type %q", c.Type)
+		}
+		p, err := ParseCapPerm(c.Permission)
+		if err != nil {
+			return nil, err
+		}
+		cs[c.Type] |= p
+	}
+	return cs, nil
+}
+
+// Union - the caps in either cs or o.
+func (cs CapSet) Union(o CapSet) CapSet {
+	out := CapSet{}
+	for t, p := range cs {
+		out[t] |= p
+	}
+	for t, p := range o {
+		out[t] |= p
+	}
+	return out.clean()
+}
+
+// Difference - the caps in cs that are not in o.  A type with read and write
+// in cs and read in o is left with write.
+func (cs CapSet) Difference(o CapSet) CapSet {
+	out := CapSet{}
+	for t, p := range cs {
+		out[t] = p &^ o[t]
+	}
+	return out.clean()
+}
+
+// IsSubset - true if every cap in cs is in o as well.
+func (cs CapSet) IsSubset(o CapSet) bool {
+	for t, p := range cs {
+		if p&^o[t] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal - true if cs and o grant the same caps.
+func (cs CapSet) Equal(o CapSet) bool {
+	return cs.IsSubset(o) && o.IsSubset(cs)
+}
+
+// Caps - the caps as UserCaps, sorted by type, for a UserCapsRequest.
+func (cs CapSet) Caps() []UserCap {
+	types := make([]string, 0, len(cs))
+	for t, p := range cs {
+		if p != 0 {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	caps := []UserCap{}
+	for _, t := range types {
+		caps = append(caps, UserCap{Type: t, Permission: cs[t].String()})
+	}
+	return caps
+}
+
+// String - implements fmt.Stringer, in the format ParseCaps reads.
+func (cs CapSet) String() string {
+	var s []string
+	for _, c := range cs.Caps() {
+		s = append(s, c.String())
+	}
+	return strings.Join(s, ";")
+}
+
+// clean - cs without types that have no permission.
+func (cs CapSet) clean() CapSet {
+	for t, p := range cs {
+		if p == 0 {
+			delete(cs, t)
+		}
+	}
+	return cs
+}
+
+// CapsSet - give the user exactly the caps in desired, with at most one CapsAdd
+// and one CapsRm.  Returns the new effective capabilities.  Nothing is sent if
+// the user already has them.
+func (aa *AdminAPI) CapsSet(ctx context.Context, uid UserID, desired CapSet) ([]UserCap, error) {
+	ui, err := aa.UserInfo(ctx, uid, false)
+	if err != nil {
+		return nil, err
+	}
+	have, err := NewCapSet(ui.Caps)
+	if err != nil {
+		return nil, err
+	}
+	caps := ui.Caps
+	if add := desired.Difference(have); len(add) > 0 {
+		caps, err = aa.CapsAdd(ctx, &UserCapsRequest{UID: uid, UserCaps: add.Caps()})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rm := have.Difference(desired); len(rm) > 0 {
+		caps, err = aa.CapsRm(ctx, &UserCapsRequest{UID: uid, UserCaps: rm.Caps()})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return caps, nil
+}
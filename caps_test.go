@@ -0,0 +1,153 @@
+package radosgwadmin_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	rgw "github.com/myENA/radosgwadmin"
+	"github.com/stretchr/testify/suite"
+)
+
+type CapsSuite struct {
+	fakeSuite
+}
+
+func (cs *CapsSuite) parse(s string) rgw.CapSet {
+	caps, err := rgw.ParseCaps(s)
+	cs.Require().NoError(err)
+	return caps
+}
+
+func (cs *CapsSuite) TestParse() {
+	cs.Equal(rgw.CapSet{"users": rgw.CapAll, "buckets": rgw.CapAll},
+		cs.parse("users=read,write;buckets=*"))
+	cs.Equal(rgw.CapSet{"usage": rgw.CapAll, "zone": rgw.CapRead},
+		cs.parse(" usage = read ; usage=write; zone=read, read;"))
+	cs.Equal(rgw.CapSet{}, cs.parse(""))
+	cs.Equal("buckets=*;users=read", cs.parse("users=read;buckets=write,read").String())
+
+	// Types are the gateway's business, including ones it doesn't know.
+	cs.Equal(rgw.CapSet{"roles": rgw.CapRead, "mdlog": rgw.CapAll, "cats": rgw.CapWrite},
+		cs.parse("roles=read;mdlog=*;;cats=write"))
+
+	for _, bad := range []string{"users", "users=", "users=sometimes", "users=read;cats", "=read"} {
+		_, err := rgw.ParseCaps(bad)
+		cs.Error(err, bad)
+	}
+
+	caps, err := rgw.NewCapSet([]rgw.UserCap{{Type: "users", Permission: "read, write"}, {Type: "usage", Permission: "read"}})
+	cs.Require().NoError(err)
+	cs.Equal(cs.parse("users=*;usage=read"), caps)
+	_, err = rgw.NewCapSet([]rgw.UserCap{{Type: "users", Permission: "full"}})
+	cs.Error(err)
+	caps, err = rgw.NewCapSet([]rgw.UserCap{{Type: "user-policy", Permission: "*"}, {Type: "ratelimit", Permission: "read"}})
+	cs.Require().NoError(err)
+	cs.Equal("ratelimit=read;user-policy=*", caps.String())
+}
+
+func (cs *CapsSuite) TestAlgebra() {
+	a := cs.parse("users=*;buckets=read")
+	b := cs.parse("buckets=write;usage=read")
+	cs.Equal(cs.parse("users=*;buckets=*;usage=read"), a.Union(b))
+	cs.Equal(cs.parse("users=*;buckets=read"), a.Difference(b))
+	cs.Equal(cs.parse("users=write"), a.Difference(cs.parse("users=read;buckets=read")))
+	cs.Equal(rgw.CapSet{}, a.Difference(a))
+
+	cs.True(cs.parse("users=read").IsSubset(a))
+	cs.True(rgw.CapSet{}.IsSubset(a))
+	cs.False(cs.parse("buckets=*").IsSubset(a))
+	cs.True(a.IsSubset(a.Union(b)))
+	cs.True(a.Equal(cs.parse("buckets=read;users=read,write")))
+	cs.False(a.Equal(b))
+	cs.Equal([]rgw.UserCap{{Type: "buckets", Permission: "read"}, {Type: "users", Permission: "*"}}, a.Caps())
+}
+
+func (cs *CapsSuite) TestValidate() {
+	// Both spellings of read and write are accepted, the gateway reports *.
+	_, err := cs.aa.UserCreate(cs.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: "ivy"}, DisplayName: "Ivy"})
+	cs.Require().NoError(err)
+	caps, err := cs.aa.CapsAdd(cs.ctx, &rgw.UserCapsRequest{UID: rgw.UserID{ID: "ivy"}, UserCaps: []rgw.UserCap{{Type: "users", Permission: "read,write"}}})
+	cs.Require().NoError(err)
+	cs.Equal([]rgw.UserCap{{Type: "users", Permission: "*"}}, caps)
+
+	_, err = cs.aa.CapsAdd(cs.ctx, &rgw.UserCapsRequest{UID: rgw.UserID{ID: "ivy"}, UserCaps: []rgw.UserCap{{Type: "users", Permission: "full"}}})
+	ve := &rgw.ValidationError{}
+	cs.Require().ErrorAs(err, &ve)
+	cs.Equal([]rgw.FieldError{{Field: "UserCaps[0].Permission", Rule: "capperm", Value: "full"}}, ve.Fields)
+
+	// Whatever ParseCaps accepts passes.
+	caps, err = cs.aa.CapsAdd(cs.ctx, &rgw.UserCapsRequest{UID: rgw.UserID{ID: "ivy"}, UserCaps: []rgw.UserCap{
+		{Type: "usage", Permission: "read, write"},
+		{Type: "zone", Permission: "write,read,read"},
+	}})
+	cs.Require().NoError(err)
+	cs.Equal([]rgw.UserCap{{Type: "usage", Permission: "*"}, {Type: "users", Permission: "*"}, {Type: "zone", Permission: "*"}}, caps)
+	_, err = cs.aa.CapsAdd(cs.ctx, &rgw.UserCapsRequest{UID: rgw.UserID{ID: "ivy"}, UserCaps: []rgw.UserCap{{Type: "users=read;zone", Permission: "read"}}})
+	cs.Require().ErrorAs(err, &ve)
+	cs.Equal("excludesall", ve.Fields[0].Rule)
+
+	// Which types exist is left to the gateway.
+	_, err = cs.aa.CapsAdd(cs.ctx, &rgw.UserCapsRequest{UID: rgw.UserID{ID: "ivy"}, UserCaps: []rgw.UserCap{{Type: "cats", Permission: "read"}}})
+	cs.True(errors.Is(err, rgw.ErrInvalidCapability), "got %v", err)
+
+	// The tags only use built in rules.
+	cs.NoError(validator.New().Struct(&rgw.UserCreateRequest{
+		UID:         rgw.UserID{ID: "ivy"},
+		DisplayName: "Ivy",
+		UserCaps:    cs.parse("roles=read,write;users=read").Caps(),
+	}))
+}
+
+func (cs *CapsSuite) TestCapsSet() {
+	uid := rgw.UserID{ID: "ivy"}
+	_, err := cs.aa.UserCreate(cs.ctx, &rgw.UserCreateRequest{
+		UID:         rgw.UserID{ID: "ivy"},
+		DisplayName: "Ivy",
+		UserCaps:    cs.parse("users=*;buckets=read").Caps(),
+	})
+	cs.Require().NoError(err)
+	desired := cs.parse("buckets=read,write;usage=read")
+
+	dry, plan := rgw.DryRun(cs.aa)
+	_, err = dry.CapsSet(cs.ctx, uid, desired)
+	cs.Require().NoError(err)
+	entries := plan.Entries()
+	cs.Require().Len(entries, 2)
+	cs.Equal("CapsAdd", entries[0].Operation)
+	cs.Equal("buckets=write;usage=read", entries[0].Params.Get("user-caps"))
+	cs.Equal("CapsRm", entries[1].Operation)
+	cs.Equal("users=*", entries[1].Params.Get("user-caps"))
+
+	caps, err := cs.aa.CapsSet(cs.ctx, uid, desired)
+	cs.Require().NoError(err)
+	cs.Equal(desired.Caps(), caps)
+	ui, err := cs.aa.UserInfo(cs.ctx, uid, false)
+	cs.Require().NoError(err)
+	cs.Equal(desired.Caps(), ui.Caps)
+
+	// Converged, nothing to send.
+	dry, plan = rgw.DryRun(cs.aa)
+	caps, err = dry.CapsSet(cs.ctx, uid, desired)
+	cs.Require().NoError(err)
+	cs.Empty(plan.Entries())
+	cs.Equal(desired.Caps(), caps)
+
+	// Types beyond the original five are read back and managed the same way.
+	desired = cs.parse("roles=*;mdlog=read;oidc-provider=write")
+	caps, err = cs.aa.CapsSet(cs.ctx, uid, desired)
+	cs.Require().NoError(err)
+	cs.Equal(desired.Caps(), caps)
+	caps, err = cs.aa.CapsSet(cs.ctx, uid, desired)
+	cs.Require().NoError(err)
+	cs.Equal(desired.Caps(), caps)
+
+	// Only removals.
+	caps, err = cs.aa.CapsSet(cs.ctx, uid, rgw.CapSet{})
+	cs.Require().NoError(err)
+	cs.Empty(caps)
+}
+
+func TestCaps(t *testing.T) {
+	suite.Run(t, new(CapsSuite))
+}
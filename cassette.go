@@ -0,0 +1,270 @@
+package radosgwadmin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CassetteMode - whether a Cassette records exchanges with the gateway or
+// replays them.
+type CassetteMode string
+
+const (
+	// CassetteRecord - requests go to the gateway, and each exchange is added to
+	// the cassette.
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplay - requests are answered from the cassette, nothing is sent
+	// to the gateway.
+	CassetteReplay CassetteMode = "replay"
+)
+
+// Redacted - what scrubbed values are replaced with, and how a Secret is shown.
+const Redacted = "REDACTED"
+
+// ErrInteractionNotFound - returned in replay mode for a request that has no
+// unused match in the cassette.
+var ErrInteractionNotFound = errors.New("no matching interaction in cassette")
+
+// RecordedRequest - the parts of a request used to match it on replay.  Query is
+// canonical: sorted by key then value, with key material scrubbed.  Header is
+// kept for reference only, with signatures scrubbed.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// RecordedResponse - a response as received from the gateway, with key material
+// scrubbed from the body.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Interaction - one request to the gateway and its response.  Operation is the
+// name of the AdminAPI method that sent it, e.g. "UserCreate".
+type Interaction struct {
+	Operation string           `json:"operation"`
+	Request   RecordedRequest  `json:"request"`
+	Response  RecordedResponse `json:"response"`
+
+	replayed bool
+}
+
+// Cassette - a list of recorded interactions, to let tests of code using
+// AdminAPI run without a gateway.  Record against a real gateway once with
+// UseCassette(c, CassetteRecord), Save the cassette, and replay it in tests with
+// LoadCassette and UseCassette(c, CassetteReplay).
+//
+// On replay, a request is answered with the first interaction not yet replayed
+// that has the same operation, method, path and query, so repeated calls get
+// their responses in the order they were recorded.
+//
+// Signature headers, and the values of access_key and secret_key in responses
+// (access-key and secret-key in queries), are scrubbed before anything is
+// recorded.
+type Cassette struct {
+	mu           sync.Mutex
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// NewCassette - an empty cassette, to record to.
+func NewCassette() *Cassette {
+	return &Cassette{Interactions: []*Interaction{}}
+}
+
+// LoadCassette - read a cassette saved with Save.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := NewCassette()
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save - write the cassette to path as json.
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Middleware - the recording or replaying step.  This needs to go after the
+// signing step, see UseCassette.
+func (c *Cassette) Middleware(mode CassetteMode) Middleware {
+	if mode == CassetteReplay {
+		return c.replay
+	}
+	return c.record
+}
+
+// UseCassette - record to, or replay from, c.  In replay mode the AdminAPI still
+// needs a ServerURL, but it is never contacted.
+func (aa *AdminAPI) UseCassette(c *Cassette, mode CassetteMode) {
+	aa.UseSigned(c.Middleware(mode))
+}
+
+func (c *Cassette) record(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if resp == nil {
+			return resp, err
+		}
+		body, rerr := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if rerr != nil {
+			return resp, err
+		}
+		in := &Interaction{
+			Request: recordRequest(req),
+			Response: RecordedResponse{
+				StatusCode: resp.StatusCode,
+				Header:     resp.Header.Clone(),
+				Body:       scrubBody(string(body)),
+			},
+		}
+		if ci := callInfoFrom(req.Context()); ci != nil {
+			in.Operation = ci.op
+		}
+		c.mu.Lock()
+		c.Interactions = append(c.Interactions, in)
+		c.mu.Unlock()
+		return resp, err
+	})
+}
+
+func (c *Cassette) replay(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		op := ""
+		if ci := callInfoFrom(req.Context()); ci != nil {
+			op = ci.op
+		}
+		rr := recordRequest(req)
+		in := c.take(op, rr)
+		if in == nil {
+			return nil, fmt.Errorf("%w: %s %s %s?%s", ErrInteractionNotFound, op, rr.Method, rr.Path, rr.Query)
+		}
+		resp := &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}
+		if resp.Header == nil {
+			resp.Header = http.Header{}
+		}
+		return checkStatus(resp)
+	})
+}
+
+// take - the first unreplayed interaction matching op and rr, marked replayed.
+func (c *Cassette) take(op string, rr RecordedRequest) *Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, in := range c.Interactions {
+		if in.replayed || in.Operation != op {
+			continue
+		}
+		if in.Request.Method == rr.Method && in.Request.Path == rr.Path && in.Request.Query == rr.Query {
+			in.replayed = true
+			return in
+		}
+	}
+	return nil
+}
+
+// scrubbedHeaders - request headers carrying a signature or a credential.
+var scrubbedHeaders = []string{"Authorization", "X-Amz-Security-Token"}
+
+// scrubbedParams - query parameters carrying key material.  The last four only
+// appear in presigned urls.
+var scrubbedParams = []string{"access-key", "secret-key", "AWSAccessKeyId", "Signature", "X-Amz-Credential", "X-Amz-Signature"}
+
+func recordRequest(req *http.Request) RecordedRequest {
+	h := req.Header.Clone()
+	for _, k := range scrubbedHeaders {
+		if h.Get(k) != "" {
+			h.Set(k, Redacted)
+		}
+	}
+	return RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  canonicalQuery(req.URL.RawQuery),
+		Header: h,
+	}
+}
+
+// canonicalQuery - rawQuery with key material scrubbed, sorted by key and then
+// value.
+func canonicalQuery(rawQuery string) string {
+	q, _ := url.ParseQuery(rawQuery)
+	for _, k := range scrubbedParams {
+		for i := range q[k] {
+			q[k][i] = Redacted
+		}
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(url.QueryEscape(k))
+			sb.WriteByte('=')
+			sb.WriteString(url.QueryEscape(v))
+		}
+	}
+	return sb.String()
+}
+
+// scrubbedFields - matches access_key and secret_key json fields with their
+// values.  Bodies are scrubbed as text because some responses, the bucket index
+// one, are not a single json document.
+var scrubbedFields = regexp.MustCompile(`("(?:access_key|secret_key)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// tempURLKeys - matches a temp_url_keys array, whose val fields are secrets.
+// Other val fields, such as those of metadata attrs, are not.
+var tempURLKeys = regexp.MustCompile(`"temp_url_keys"\s*:\s*\[(?:[^\]"]|"(?:[^"\\]|\\.)*")*\]`)
+
+// tempURLVals - matches the val fields in a temp_url_keys array.
+var tempURLVals = regexp.MustCompile(`("val"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+func scrubBody(body string) string {
+	body = tempURLKeys.ReplaceAllStringFunc(body, func(keys string) string {
+		return tempURLVals.ReplaceAllString(keys, `$1"`+Redacted+`"`)
+	})
+	return scrubbedFields.ReplaceAllString(body, `$1"`+Redacted+`"`)
+}
@@ -0,0 +1,161 @@
+package radosgwadmin
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CassetteSuite struct {
+	suite.Suite
+	ctx  context.Context
+	gw   *httptest.Server
+	hits int
+}
+
+func (cs *CassetteSuite) SetupTest() {
+	cs.ctx = context.Background()
+	cs.hits = 0
+	cs.gw = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cs.hits++
+		file := ""
+		switch {
+		case r.URL.Query().Get("uid") == "ghost":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"Code":"NoSuchUser"}`))
+			return
+		case r.URL.Query().Get("uid") == "tempurl":
+			file = "userfull.json"
+		case r.URL.Path == "/admin/user":
+			file = "user.json"
+		case r.URL.Path == "/admin/bucket":
+			file = "bucketindex.json"
+		}
+		data, err := ioutil.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+}
+
+func (cs *CassetteSuite) TearDownTest() {
+	cs.gw.Close()
+}
+
+func (cs *CassetteSuite) newAdminAPI(c *Cassette, mode CassetteMode) *AdminAPI {
+	aa, err := NewAdminAPI(&Config{
+		ServerURL:       cs.gw.URL,
+		AdminPath:       "admin",
+		AccessKeyID:     "0555b35654ad1656d804",
+		SecretAccessKey: "h7GhxuBLTrlhVUyxSPUKUV8r",
+	})
+	cs.Require().NoError(err)
+	aa.UseCassette(c, mode)
+	return aa
+}
+
+func (cs *CassetteSuite) TestRecordReplay() {
+	path := filepath.Join(cs.T().TempDir(), "cassette.json")
+	rec := NewCassette()
+	aa := cs.newAdminAPI(rec, CassetteRecord)
+
+	recorded, err := aa.UserCreate(cs.ctx, &UserCreateRequest{UID: UserID{ID: "testuser"}, DisplayName: "testuser"})
+	cs.Require().NoError(err)
+	index, err := aa.BucketIndex(cs.ctx, &BucketIndexRequest{Bucket: "pics", CheckObjects: true})
+	cs.Require().NoError(err)
+	_, err = aa.UserInfo(cs.ctx, UserID{ID: "ghost"}, false)
+	cs.True(errors.Is(err, ErrNoSuchUser))
+	cs.Require().NoError(rec.Save(path))
+	cs.Equal(3, cs.hits)
+
+	// Nothing secret makes it to disk.
+	data, err := ioutil.ReadFile(path)
+	cs.Require().NoError(err)
+	for _, secret := range []string{"h7GhxuBLTrlhVUyxSPUKUV8r", "0555b35654ad1656d804", "imalasagnahoggohangasalami", "werqwerqwerqwerqwerwer"} {
+		cs.NotContains(string(data), secret)
+	}
+	cs.Contains(string(data), `"Authorization": [`+"\n"+`            "REDACTED"`)
+
+	// Replay without the gateway.
+	cs.gw.Close()
+	play, err := LoadCassette(path)
+	cs.Require().NoError(err)
+	aa = cs.newAdminAPI(play, CassetteReplay)
+
+	replayed, err := aa.UserCreate(cs.ctx, &UserCreateRequest{UID: UserID{ID: "testuser"}, DisplayName: "testuser"})
+	cs.Require().NoError(err)
+	cs.Equal(recorded.UserID, replayed.UserID)
+	cs.Equal(len(recorded.Keys), len(replayed.Keys))
+	for _, k := range replayed.Keys {
+		cs.Equal(Redacted, k.AccessKey)
+		cs.Equal(Redacted, k.SecretKey.Reveal())
+	}
+
+	rindex, err := aa.BucketIndex(cs.ctx, &BucketIndexRequest{Bucket: "pics", CheckObjects: true})
+	cs.Require().NoError(err)
+	cs.Equal(index, rindex)
+
+	_, err = aa.UserInfo(cs.ctx, UserID{ID: "ghost"}, false)
+	cs.True(errors.Is(err, ErrNoSuchUser), "expected NoSuchUser, got %v", err)
+
+	// Each interaction is replayed once, and requests must match.
+	_, err = aa.UserCreate(cs.ctx, &UserCreateRequest{UID: UserID{ID: "testuser"}, DisplayName: "testuser"})
+	cs.True(errors.Is(err, ErrInteractionNotFound), "expected a miss, got %v", err)
+	_, err = aa.UserInfo(cs.ctx, UserID{ID: "someone"}, false)
+	cs.True(errors.Is(err, ErrInteractionNotFound), "expected a miss, got %v", err)
+	cs.Equal(3, cs.hits)
+}
+
+func (cs *CassetteSuite) TestTempURLKeys() {
+	path := filepath.Join(cs.T().TempDir(), "cassette.json")
+	rec := NewCassette()
+	aa := cs.newAdminAPI(rec, CassetteRecord)
+	recorded, err := aa.UserInfo(cs.ctx, UserID{ID: "tempurl"}, false)
+	cs.Require().NoError(err)
+	cs.Require().NotEmpty(recorded.TempURLKeys)
+	cs.Equal("swordfish", recorded.TempURLKeys[0].Val.Reveal())
+	cs.Require().NoError(rec.Save(path))
+
+	data, err := ioutil.ReadFile(path)
+	cs.Require().NoError(err)
+	cs.NotContains(string(data), "swordfish")
+
+	play, err := LoadCassette(path)
+	cs.Require().NoError(err)
+	aa = cs.newAdminAPI(play, CassetteReplay)
+	replayed, err := aa.UserInfo(cs.ctx, UserID{ID: "tempurl"}, false)
+	cs.Require().NoError(err)
+	cs.Require().Len(replayed.TempURLKeys, len(recorded.TempURLKeys))
+	cs.Equal(Redacted, replayed.TempURLKeys[0].Val.Reveal())
+}
+
+func (cs *CassetteSuite) TestCanonicalQuery() {
+	cs.Equal(
+		canonicalQuery("uid=u&key&access-key=AK&secret-key=SK&subuser=b&subuser=a"),
+		canonicalQuery("subuser=a&secret-key=other&access-key=AK2&key&subuser=b&uid=u"),
+	)
+	cs.Equal("access-key=REDACTED&key=&uid=u", canonicalQuery("uid=u&key&access-key=AK"))
+}
+
+func (cs *CassetteSuite) TestScrubBody() {
+	cs.Equal(
+		`[{"user":"u","access_key":"REDACTED","secret_key" : "REDACTED"}]`,
+		scrubBody(`[{"user":"u","access_key":"AK","secret_key" : "a\"b\\"}]`),
+	)
+	cs.Equal(
+		`{"temp_url_keys":[{"key":0,"val":"REDACTED"},{"key":1,"val" : "REDACTED"}],"attrs":[{"key":"k","val":"v"}]}`,
+		scrubBody(`{"temp_url_keys":[{"key":0,"val":"a]b"},{"key":1,"val" : "c\"d"}],"attrs":[{"key":"k","val":"v"}]}`),
+	)
+}
+
+func TestCassette(t *testing.T) {
+	suite.Run(t, new(CassetteSuite))
+}
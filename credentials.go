@@ -0,0 +1,252 @@
+package radosgwadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultExpiryWindow - credentials that expire within this window are
+// considered expired and are refreshed before they are used.
+const DefaultExpiryWindow = 5 * time.Minute
+
+// Credentials - the key material used to sign a request.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey Secret
+	SecurityToken   Secret
+	Expiration      time.Time // zero means no expiration
+}
+
+// expiresWithin - true if the credentials have an expiration and it is less than
+// window away.
+func (c *Credentials) expiresWithin(window time.Duration) bool {
+	return !c.Expiration.IsZero() && time.Until(c.Expiration) < window
+}
+
+// CredentialsProvider - AdminAPI asks its provider for credentials before signing
+// each request, so implementations are free to rotate keys at any time.
+// Retrieve is called concurrently and should be cheap when nothing has changed.
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// ErrCredentialsExpired - returned by providers when the only credentials
+// available have expired.
+var ErrCredentialsExpired = errors.New("credentials expired")
+
+// StaticCredentials - a fixed set of credentials.  This is what NewAdminAPI uses
+// when Config.CredentialsProvider is not set.
+type StaticCredentials Credentials
+
+// Retrieve - implements CredentialsProvider
+func (sc StaticCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	c := Credentials(sc)
+	if c.expiresWithin(0) {
+		return Credentials{}, ErrCredentialsExpired
+	}
+	return c, nil
+}
+
+// EnvCredentials - reads credentials from the environment on every call.  The
+// variable names default to the usual AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN if not specified.
+type EnvCredentials struct {
+	AccessKeyIDVar     string
+	SecretAccessKeyVar string
+	SecurityTokenVar   string
+}
+
+// Retrieve - implements CredentialsProvider
+func (ec *EnvCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	akVar, skVar, tokVar := "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"
+	if ec.AccessKeyIDVar != "" {
+		akVar = ec.AccessKeyIDVar
+	}
+	if ec.SecretAccessKeyVar != "" {
+		skVar = ec.SecretAccessKeyVar
+	}
+	if ec.SecurityTokenVar != "" {
+		tokVar = ec.SecurityTokenVar
+	}
+	c := Credentials{
+		AccessKeyID:     os.Getenv(akVar),
+		SecretAccessKey: Secret(os.Getenv(skVar)),
+		SecurityToken:   Secret(os.Getenv(tokVar)),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("environment variables %s and %s must both be set", akVar, skVar)
+	}
+	return c, nil
+}
+
+// credentialsDocument - the json document read by FileCredentials and
+// ProcessCredentials.  This is the same format the aws cli expects from a
+// credential_process command.
+type credentialsDocument struct {
+	Version         int       `json:"Version"`
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"SessionToken"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func parseCredentialsDocument(data []byte) (Credentials, error) {
+	doc := &credentialsDocument{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return Credentials{}, err
+	}
+	if doc.Version != 0 && doc.Version != 1 {
+		return Credentials{}, fmt.Errorf("unsupported credentials document version %d", doc.Version)
+	}
+	if doc.AccessKeyID == "" || doc.SecretAccessKey == "" {
+		return Credentials{}, errors.New("credentials document is missing AccessKeyId or SecretAccessKey")
+	}
+	return Credentials{
+		AccessKeyID:     doc.AccessKeyID,
+		SecretAccessKey: Secret(doc.SecretAccessKey),
+		SecurityToken:   Secret(doc.SessionToken),
+		Expiration:      doc.Expiration,
+	}, nil
+}
+
+// credentialsCache - shared by the providers that are expensive to query.
+type credentialsCache struct {
+	mu     sync.Mutex
+	creds  *Credentials
+	window time.Duration
+}
+
+// get - returns the cached credentials unless they are missing, stale, or about to
+// expire, in which case fetch is called to replace them.
+func (cc *credentialsCache) get(stale bool, fetch func() (Credentials, error)) (Credentials, error) {
+	window := cc.window
+	if window == 0 {
+		window = DefaultExpiryWindow
+	}
+	if !stale && cc.creds != nil && !cc.creds.expiresWithin(window) {
+		return *cc.creds, nil
+	}
+	c, err := fetch()
+	if err != nil {
+		return Credentials{}, err
+	}
+	if c.expiresWithin(0) {
+		return Credentials{}, ErrCredentialsExpired
+	}
+	cc.creds = &c
+	return c, nil
+}
+
+// FileCredentials - reads credentials from a json file in the credential_process
+// format:
+//
+//	{
+//	    "Version": 1,
+//	    "AccessKeyId": "...",
+//	    "SecretAccessKey": "...",
+//	    "SessionToken": "...",
+//	    "Expiration": "2006-01-02T15:04:05Z"
+//	}
+//
+// The file is watched, it is re-read whenever its modification time or size
+// changes, or when the credentials in it are about to expire.  This lets an
+// external agent rotate keys by rewriting the file.
+type FileCredentials struct {
+	Path string
+	// ExpiryWindow - defaults to DefaultExpiryWindow
+	ExpiryWindow time.Duration
+
+	cache   credentialsCache
+	modTime time.Time
+	size    int64
+}
+
+// NewFileCredentials - FileCredentials factory method.
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{Path: path}
+}
+
+// Retrieve - implements CredentialsProvider
+func (fc *FileCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	fi, err := os.Stat(fc.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	fc.cache.mu.Lock()
+	defer fc.cache.mu.Unlock()
+	fc.cache.window = fc.ExpiryWindow
+	changed := !fi.ModTime().Equal(fc.modTime) || fi.Size() != fc.size
+	return fc.cache.get(changed, func() (Credentials, error) {
+		data, err := ioutil.ReadFile(fc.Path)
+		if err != nil {
+			return Credentials{}, err
+		}
+		c, err := parseCredentialsDocument(data)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("cannot parse credentials file %s: %s", fc.Path, err)
+		}
+		fc.modTime, fc.size = fi.ModTime(), fi.Size()
+		return c, nil
+	})
+}
+
+// ProcessCredentials - runs an external command, like the aws cli
+// credential_process setting, and reads credentials from its standard output
+// in the format described for FileCredentials.  The command is run again
+// only once the credentials it returned are about to expire; credentials
+// without an Expiration are kept for the life of the provider.
+type ProcessCredentials struct {
+	// Command - program and arguments.  It is not run through a shell.
+	Command []string
+	// Timeout - defaults to one minute
+	Timeout time.Duration
+	// ExpiryWindow - defaults to DefaultExpiryWindow
+	ExpiryWindow time.Duration
+
+	cache credentialsCache
+}
+
+// NewProcessCredentials - ProcessCredentials factory method.
+func NewProcessCredentials(command ...string) *ProcessCredentials {
+	return &ProcessCredentials{Command: command}
+}
+
+// Retrieve - implements CredentialsProvider
+func (pc *ProcessCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	if len(pc.Command) == 0 {
+		return Credentials{}, errors.New("no credential process command specified")
+	}
+	pc.cache.mu.Lock()
+	defer pc.cache.mu.Unlock()
+	pc.cache.window = pc.ExpiryWindow
+	return pc.cache.get(false, func() (Credentials, error) {
+		timeout := pc.Timeout
+		if timeout == 0 {
+			timeout = time.Minute
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		cmd := exec.CommandContext(ctx, pc.Command[0], pc.Command[1:]...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			return Credentials{}, fmt.Errorf("credential process %s failed: %s: %s",
+				pc.Command[0], err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		c, err := parseCredentialsDocument(stdout.Bytes())
+		if err != nil {
+			return Credentials{}, fmt.Errorf("cannot parse output of credential process %s: %s", pc.Command[0], err)
+		}
+		return c, nil
+	})
+}
@@ -0,0 +1,188 @@
+package radosgwadmin
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CredentialsSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (cs *CredentialsSuite) SetupTest() {
+	var err error
+	cs.dir, err = ioutil.TempDir("", "rgwcreds")
+	cs.Require().NoError(err)
+}
+
+func (cs *CredentialsSuite) TearDownTest() {
+	_ = os.RemoveAll(cs.dir)
+}
+
+func credentialsJSON(ak, sk string, exp time.Time) string {
+	doc := fmt.Sprintf(`{"Version":1,"AccessKeyId":%q,"SecretAccessKey":%q`, ak, sk)
+	if !exp.IsZero() {
+		doc += fmt.Sprintf(`,"Expiration":%q`, exp.UTC().Format(time.RFC3339))
+	}
+	return doc + "}"
+}
+
+func (cs *CredentialsSuite) TestStatic() {
+	c, err := StaticCredentials{AccessKeyID: "a", SecretAccessKey: "b"}.Retrieve(context.Background())
+	cs.NoError(err)
+	cs.Equal("a", c.AccessKeyID)
+
+	_, err = StaticCredentials{AccessKeyID: "a", SecretAccessKey: "b", Expiration: time.Now().Add(-time.Hour)}.
+		Retrieve(context.Background())
+	cs.Equal(ErrCredentialsExpired, err)
+}
+
+func (cs *CredentialsSuite) TestEnv() {
+	os.Setenv("RGWTEST_AK", "envak")
+	os.Setenv("RGWTEST_SK", "envsk")
+	defer os.Unsetenv("RGWTEST_AK")
+	defer os.Unsetenv("RGWTEST_SK")
+
+	ec := &EnvCredentials{AccessKeyIDVar: "RGWTEST_AK", SecretAccessKeyVar: "RGWTEST_SK", SecurityTokenVar: "RGWTEST_TOK"}
+	c, err := ec.Retrieve(context.Background())
+	cs.NoError(err)
+	cs.Equal("envak", c.AccessKeyID)
+	cs.Equal("envsk", c.SecretAccessKey.Reveal())
+
+	// rotation is picked up on the next call.
+	os.Setenv("RGWTEST_AK", "envak2")
+	c, err = ec.Retrieve(context.Background())
+	cs.NoError(err)
+	cs.Equal("envak2", c.AccessKeyID)
+
+	os.Unsetenv("RGWTEST_SK")
+	_, err = ec.Retrieve(context.Background())
+	cs.Error(err)
+}
+
+func (cs *CredentialsSuite) TestFile() {
+	path := filepath.Join(cs.dir, "creds.json")
+	cs.Require().NoError(ioutil.WriteFile(path, []byte(credentialsJSON("ak1", "sk1", time.Time{})), 0600))
+
+	fc := NewFileCredentials(path)
+	c, err := fc.Retrieve(context.Background())
+	cs.NoError(err)
+	cs.Equal("ak1", c.AccessKeyID)
+
+	// rewrite the file, with a different size and an mtime in the future so the
+	// change is visible regardless of file system timestamp granularity.
+	cs.Require().NoError(ioutil.WriteFile(path, []byte(credentialsJSON("ak22", "sk22", time.Time{})), 0600))
+	future := time.Now().Add(time.Minute)
+	cs.Require().NoError(os.Chtimes(path, future, future))
+	c, err = fc.Retrieve(context.Background())
+	cs.NoError(err)
+	cs.Equal("ak22", c.AccessKeyID)
+	cs.Equal("sk22", c.SecretAccessKey.Reveal())
+
+	// expired credentials in the file are an error.
+	cs.Require().NoError(ioutil.WriteFile(path, []byte(credentialsJSON("ak3", "sk3", time.Now().Add(-time.Hour))), 0600))
+	_, err = fc.Retrieve(context.Background())
+	cs.Equal(ErrCredentialsExpired, err)
+
+	cs.Require().NoError(ioutil.WriteFile(path, []byte("not json"), 0600))
+	_, err = fc.Retrieve(context.Background())
+	cs.Error(err)
+}
+
+func (cs *CredentialsSuite) TestProcess() {
+	counter := filepath.Join(cs.dir, "count")
+	pc := NewProcessCredentials(os.Args[0], "-test.run=TestCredentialsHelperProcess", "--", counter)
+	pc.ExpiryWindow = time.Minute
+
+	// The helper hands out credentials expiring in 30s, inside the expiry
+	// window, so every call runs the process again.
+	os.Setenv("RGW_CREDS_HELPER", "expiring")
+	c, err := pc.Retrieve(context.Background())
+	cs.Require().NoError(err)
+	cs.Equal("proc1", c.AccessKeyID)
+	c, err = pc.Retrieve(context.Background())
+	cs.Require().NoError(err)
+	cs.Equal("proc2", c.AccessKeyID)
+
+	// Credentials with a distant expiration are cached.
+	os.Setenv("RGW_CREDS_HELPER", "longlived")
+	pc.cache.creds = nil
+	c, err = pc.Retrieve(context.Background())
+	cs.Require().NoError(err)
+	cs.Equal("proc3", c.AccessKeyID)
+	c, err = pc.Retrieve(context.Background())
+	cs.Require().NoError(err)
+	cs.Equal("proc3", c.AccessKeyID, "credentials should have been cached")
+
+	os.Setenv("RGW_CREDS_HELPER", "fail")
+	pc.cache.creds = nil
+	_, err = pc.Retrieve(context.Background())
+	cs.Error(err)
+	cs.Contains(err.Error(), "no credentials for you")
+	os.Unsetenv("RGW_CREDS_HELPER")
+}
+
+// TestCredentialsHelperProcess - not a real test, this is the external command run
+// by TestProcess.
+func TestCredentialsHelperProcess(t *testing.T) {
+	mode := os.Getenv("RGW_CREDS_HELPER")
+	if mode == "" {
+		return
+	}
+	counter := os.Args[len(os.Args)-1]
+	b, _ := ioutil.ReadFile(counter)
+	n := len(b) + 1
+	_ = ioutil.WriteFile(counter, []byte(strings.Repeat("x", n)), 0600)
+	switch mode {
+	case "expiring":
+		fmt.Print(credentialsJSON(fmt.Sprintf("proc%d", n), "sk", time.Now().Add(30*time.Second)))
+	case "longlived":
+		fmt.Print(credentialsJSON(fmt.Sprintf("proc%d", n), "sk", time.Now().Add(time.Hour)))
+	default:
+		fmt.Fprint(os.Stderr, "no credentials for you")
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func (cs *CredentialsSuite) TestAdminAPIUsesProvider() {
+	path := filepath.Join(cs.dir, "creds.json")
+	cs.Require().NoError(ioutil.WriteFile(path, []byte(credentialsJSON("first", "sk1", time.Time{})), 0600))
+
+	aa, err := NewAdminAPI(&Config{
+		ServerURL:           "http://rgw.example.com",
+		AdminPath:           "admin",
+		AccessKeyID:         "ignored",
+		CredentialsProvider: NewFileCredentials(path),
+	})
+	cs.Require().NoError(err)
+
+	req, _ := http.NewRequest("GET", "http://rgw.example.com/admin/user?uid=a", nil)
+	cs.Require().NoError(aa.sign(req))
+	cs.True(strings.HasPrefix(req.Header.Get("Authorization"), "AWS first:"))
+
+	cs.Require().NoError(ioutil.WriteFile(path, []byte(credentialsJSON("second", "sk2", time.Time{})), 0600))
+	future := time.Now().Add(time.Minute)
+	cs.Require().NoError(os.Chtimes(path, future, future))
+	req, _ = http.NewRequest("GET", "http://rgw.example.com/admin/user?uid=a", nil)
+	cs.Require().NoError(aa.sign(req))
+	cs.True(strings.HasPrefix(req.Header.Get("Authorization"), "AWS second:"))
+
+	cs.Require().NoError(os.Remove(path))
+	req, _ = http.NewRequest("GET", "http://rgw.example.com/admin/user?uid=a", nil)
+	cs.Error(aa.sign(req), "signing should fail when the provider does")
+}
+
+func TestCredentials(t *testing.T) {
+	suite.Run(t, new(CredentialsSuite))
+}
@@ -39,6 +39,11 @@ Example app:
         fmt.Println(users)
     }
 
-Input validation is provided by https://godoc.org/gopkg.in/go-playground/validator.v9
+Errors returned by the gateway are of type *RGWError, and can be tested for with
+errors.Is against the Err* sentinels, e.g. errors.Is(err, ErrNoSuchUser).
+
+Requests are checked against their validate tags before anything is sent, and
+those that fail return a *ValidationError.  Input validation is provided by
+https://pkg.go.dev/github.com/go-playground/validator/v10
 */
 package radosgwadmin
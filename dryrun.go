@@ -0,0 +1,355 @@
+package radosgwadmin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/myENA/restclient"
+)
+
+// PlanEntry - a mutating call that a dry run AdminAPI did not make.
+type PlanEntry struct {
+	// Operation - name of the AdminAPI method, e.g. "UserCreate".
+	Operation string
+	// Method - http method.
+	Method string
+	// Path - path relative to the admin path, with any subresource, e.g.
+	// "/user?key".
+	Path string
+	// Params - the query parameters that would have been sent, with the
+	// secret key redacted.
+	Params url.Values
+}
+
+// String - implements fmt.Stringer
+func (pe PlanEntry) String() string {
+	s := pe.Operation + ": " + pe.Method + " " + pe.Path
+	if qs := pe.Params.Encode(); qs != "" {
+		if strings.Contains(pe.Path, "?") {
+			s += "&" + qs
+		} else {
+			s += "?" + qs
+		}
+	}
+	return s
+}
+
+// Plan - the mutating calls made through a dry run AdminAPI, in order.  It is
+// safe for concurrent use.
+type Plan struct {
+	mu      sync.Mutex
+	entries []PlanEntry
+}
+
+// Entries - a copy of the entries so far.
+func (p *Plan) Entries() []PlanEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PlanEntry(nil), p.entries...)
+}
+
+// String - implements fmt.Stringer, one entry per line.
+func (p *Plan) String() string {
+	var b strings.Builder
+	for _, pe := range p.Entries() {
+		b.WriteString(pe.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (p *Plan) add(pe PlanEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = append(p.entries, pe)
+}
+
+// DryRun - an AdminAPI sharing aa's configuration that only sends read
+// requests.  Every other call, including a BucketIndex with Fix set, is
+// validated, added to the returned Plan, and answered with a result synthesized
+// from the request and, where that needs the current state, from reads of it.
+// For example, CapsAdd reads the user's caps and returns them with the new ones
+// added, KeyCreate returns the user's keys plus the one asked for, and a
+// BucketIndex fix returns what a check finds.  Generated keys, which only the
+// gateway can make, are left empty.
+//
+// Errors a read turns up, such as ErrNoSuchUser for UserModify of a user that
+// does not exist, are returned as they would be by the real call.  Other
+// errors, such as ErrUserAlreadyExists, are not detected.
+//
+// The dry run AdminAPI starts with aa's middleware, and has an http client of
+// its own, so middleware or a cassette added to either one afterwards is not
+// used by the other.
+func DryRun(aa *AdminAPI) (*AdminAPI, *Plan) {
+	dry := *aa
+	dry.plan = &Plan{}
+	dry.middleware = append([]Middleware(nil), aa.middleware...)
+	dry.signedMiddleware = append([]Middleware(nil), aa.signedMiddleware...)
+
+	c := *aa.Client
+	hc := *c.Client
+	if at, ok := hc.Transport.(*adminTransport); ok {
+		hc.Transport = &adminTransport{aa: &dry, base: at.base}
+	}
+	c.Client = &hc
+	dry.BaseClient = &restclient.BaseClient{Client: &c, BaseURL: aa.BaseURL}
+	return &dry, dry.plan
+}
+
+// mutates - whether a call changes anything, and so is planned by a dry run.
+// That is every call but a GET, and a BucketIndex that fixes the index.
+func mutates(method string, queryStruct interface{}) bool {
+	if bireq, ok := queryStruct.(*BucketIndexRequest); ok && bireq.Fix {
+		return true
+	}
+	return method != http.MethodGet
+}
+
+// planCall - record the call in the plan, and fill in responseBody.
+func (aa *AdminAPI) planCall(ctx context.Context, op, method, path string, q url.Values, queryStruct, responseBody interface{}) error {
+	params := url.Values{}
+	for k, vs := range q {
+		if k == "secret-key" {
+			vs = []string{Redacted}
+		}
+		params[k] = vs
+	}
+	aa.plan.add(PlanEntry{Operation: op, Method: method, Path: path, Params: params})
+	if responseBody == nil {
+		return nil
+	}
+	switch req := queryStruct.(type) {
+	case *UserCreateRequest:
+		resp := responseBody.(*UserInfoResponse)
+		uid := req.UID
+		*resp = UserInfoResponse{
+			Tenant:      uid.Tenant,
+			UserID:      uid.String(),
+			DisplayName: req.DisplayName,
+			Email:       req.Email,
+			MaxBuckets:  req.MaxBuckets,
+			SubUsers:    []SubUser{},
+			Keys:        []UserKey{},
+			SwiftKeys:   []SwiftKey{},
+			Caps:        append([]UserCap{}, req.UserCaps...),
+			Suspended:   req.Suspended,
+			OpMask:      req.OpMask,
+
+			DefaultPlacement:    req.DefaultPlacement,
+			PlacementTags:       append([]string{}, req.PlacementTags...),
+			DefaultStorageClass: req.DefaultStorageClass,
+			Admin:               req.Admin,
+			System:              req.System,
+		}
+		if resp.OpMask == 0 {
+			resp.OpMask = OpAll
+		}
+		if req.KeyType == "swift" {
+			resp.SwiftKeys = append(resp.SwiftKeys, SwiftKey{User: uid.String(), SecretKey: Secret(req.SecretKey)})
+		} else if req.GenerateKey == nil || *req.GenerateKey || req.AccessKey != "" {
+			resp.Keys = append(resp.Keys, UserKey{User: uid.String(), AccessKey: req.AccessKey, SecretKey: Secret(req.SecretKey)})
+		}
+	case *UserModifyRequest:
+		resp := responseBody.(*UserInfoResponse)
+		uir, err := aa.UserInfo(ctx, req.UID, false)
+		if err != nil {
+			return err
+		}
+		*resp = *uir
+		if req.DisplayName != "" {
+			resp.DisplayName = req.DisplayName
+		}
+		if req.Email != "" {
+			resp.Email = req.Email
+		}
+		if req.MaxBuckets != 0 {
+			resp.MaxBuckets = req.MaxBuckets
+		}
+		if req.Suspended != nil {
+			resp.Suspended = *req.Suspended
+		}
+		if req.OpMask != 0 {
+			resp.OpMask = req.OpMask
+		}
+		if req.DefaultPlacement != "" {
+			resp.DefaultPlacement = req.DefaultPlacement
+		}
+		if req.PlacementTags != nil {
+			resp.PlacementTags = req.PlacementTags
+		}
+		if req.DefaultStorageClass != "" {
+			resp.DefaultStorageClass = req.DefaultStorageClass
+		}
+		if req.Admin != nil {
+			resp.Admin = *req.Admin
+		}
+		if req.System != nil {
+			resp.System = *req.System
+		}
+		if req.UserCaps != nil {
+			if resp.Caps, err = addCaps(resp.Caps, req.UserCaps); err != nil {
+				return err
+			}
+		}
+		if req.AccessKey != "" || req.GenerateKey {
+			k := UserKey{User: req.UID.String(), AccessKey: req.AccessKey, SecretKey: Secret(req.SecretKey)}
+			resp.Keys = putKey(resp.Keys, k)
+		}
+	case *KeyCreateRequest:
+		resp := responseBody.(*[]UserKey)
+		uir, err := aa.UserInfo(ctx, req.UID, false)
+		if err != nil {
+			return err
+		}
+		user := req.UID.String()
+		if req.SubUser != "" {
+			user = subUserID(req.UID, req.SubUser)
+		}
+		if req.KeyType == "swift" || (req.KeyType == "" && req.SubUser != "") {
+			keys := []UserKey{}
+			for _, sk := range uir.SwiftKeys {
+				if sk.User != user {
+					keys = append(keys, UserKey{User: sk.User, SecretKey: sk.SecretKey})
+				}
+			}
+			*resp = append(keys, UserKey{User: user, SecretKey: Secret(req.SecretKey)})
+		} else {
+			*resp = putKey(append([]UserKey{}, uir.Keys...), UserKey{User: user, AccessKey: req.AccessKey, SecretKey: Secret(req.SecretKey)})
+		}
+	case *KeyModifyRequest:
+		resp := responseBody.(*[]UserKey)
+		uir, err := aa.UserInfo(ctx, req.UID, false)
+		if err != nil {
+			return err
+		}
+		*resp = []UserKey{}
+		if req.KeyType == "swift" {
+			user := req.UID.String()
+			if req.SubUser != "" {
+				user = subUserID(req.UID, req.SubUser)
+			}
+			for _, sk := range uir.SwiftKeys {
+				k := UserKey{User: sk.User, SecretKey: sk.SecretKey, Active: sk.Active, CreateDate: sk.CreateDate}
+				if sk.User == user {
+					k.Active = req.Active
+				}
+				*resp = append(*resp, k)
+			}
+		} else {
+			for _, k := range uir.Keys {
+				if k.AccessKey == req.AccessKey {
+					k.Active = req.Active
+				}
+				*resp = append(*resp, k)
+			}
+		}
+	case *SubUserCreateModifyRequest:
+		resp := responseBody.(*[]SubUser)
+		uir, err := aa.UserInfo(ctx, req.UID, false)
+		if err != nil {
+			return err
+		}
+		id := subUserID(req.UID, req.SubUser)
+		su := SubUser{ID: id, Permissions: subUserPermissions(req.Access)}
+		*resp = []SubUser{}
+		for _, s := range uir.SubUsers {
+			if s.ID != id {
+				*resp = append(*resp, s)
+			} else if req.Access == "" {
+				su.Permissions = s.Permissions
+			}
+		}
+		*resp = append(*resp, su)
+	case *BucketIndexRequest:
+		// What a check finds, which the fix would have fixed.
+		check := *req
+		check.Fix = false
+		bir, err := aa.BucketIndex(ctx, &check)
+		if err != nil {
+			return err
+		}
+		*responseBody.(*BucketIndexResponse) = *bir
+	case *UserCapsRequest:
+		resp := responseBody.(*[]UserCap)
+		uir, err := aa.UserInfo(ctx, req.UID, false)
+		if err != nil {
+			return err
+		}
+		if method == http.MethodDelete {
+			*resp, err = rmCaps(uir.Caps, req.UserCaps)
+		} else {
+			*resp, err = addCaps(uir.Caps, req.UserCaps)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subUserID - the full id of a subuser, which may be given with or without the
+// uid prefix.
+func subUserID(uid UserID, subuser string) string {
+	if strings.Contains(subuser, ":") {
+		return subuser
+	}
+	return uid.String() + ":" + subuser
+}
+
+// subUserPermissions - how the gateway reports the access levels it is given.
+func subUserPermissions(access string) string {
+	switch access {
+	case "readwrite":
+		return "read-write"
+	case "full":
+		return "full-control"
+	case "":
+		return "<none>"
+	}
+	return access
+}
+
+// putKey - keys with k added, replacing any key with the same access key.
+func putKey(keys []UserKey, k UserKey) []UserKey {
+	out := []UserKey{}
+	for _, o := range keys {
+		if k.AccessKey == "" || o.AccessKey != k.AccessKey {
+			out = append(out, o)
+		}
+	}
+	return append(out, k)
+}
+
+// mergeCaps - caps with changes combined in by op.  Types keep their place,
+// new ones go last.
+func mergeCaps(caps, changes []UserCap, op func(have, change CapSet) CapSet) ([]UserCap, error) {
+	have, err := NewCapSet(caps)
+	if err != nil {
+		return nil, err
+	}
+	change, err := NewCapSet(changes)
+	if err != nil {
+		return nil, err
+	}
+	merged := op(have, change)
+	out := []UserCap{}
+	seen := map[string]bool{}
+	for _, c := range append(append([]UserCap{}, caps...), changes...) {
+		if !seen[c.Type] && merged[c.Type] != 0 {
+			seen[c.Type] = true
+			out = append(out, UserCap{Type: c.Type, Permission: merged[c.Type].String()})
+		}
+	}
+	return out, nil
+}
+
+func addCaps(caps, add []UserCap) ([]UserCap, error) {
+	return mergeCaps(caps, add, CapSet.Union)
+}
+
+func rmCaps(caps, rm []UserCap) ([]UserCap, error) {
+	return mergeCaps(caps, rm, CapSet.Difference)
+}
@@ -0,0 +1,185 @@
+package radosgwadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DryRunSuite struct {
+	suite.Suite
+	ctx    context.Context
+	gw     *httptest.Server
+	writes int
+	aa     *AdminAPI
+	plan   *Plan
+}
+
+func (ds *DryRunSuite) SetupTest() {
+	ds.ctx = context.Background()
+	ds.writes = 0
+	ds.gw = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Query().Get("fix") != "" {
+			ds.writes++
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/admin/bucket" {
+			data, _ := ioutil.ReadFile(filepath.Join("testdata", "bucketindex.json"))
+			_, _ = w.Write(data)
+			return
+		}
+		if r.URL.Query().Get("uid") != "testuser" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"Code":"NoSuchUser"}`))
+			return
+		}
+		data, _ := ioutil.ReadFile(filepath.Join("testdata", "user.json"))
+		_, _ = w.Write(data)
+	}))
+	aa, err := NewAdminAPI(&Config{
+		ServerURL:       ds.gw.URL,
+		AdminPath:       "admin",
+		AccessKeyID:     "a",
+		SecretAccessKey: "b",
+	})
+	ds.Require().NoError(err)
+	ds.aa, ds.plan = DryRun(aa)
+}
+
+func (ds *DryRunSuite) TearDownTest() {
+	ds.gw.Close()
+	ds.Equal(0, ds.writes, "dry run sent a mutating request")
+}
+
+func (ds *DryRunSuite) TestPlan() {
+	uir, err := ds.aa.UserCreate(ds.ctx, &UserCreateRequest{UID: UserID{ID: "newuser"}, DisplayName: "New User", Email: "new@example.com"})
+	ds.Require().NoError(err)
+	ds.Equal("newuser", uir.UserID)
+	ds.Equal("new@example.com", uir.Email)
+	ds.Len(uir.Keys, 1)
+
+	ds.NoError(ds.aa.QuotaSet(ds.ctx, &QuotaSetRequest{UID: UserID{ID: "newuser"}, QuotaType: "user", MaximumObjects: 100, Enabled: true}))
+	ds.NoError(ds.aa.BucketRm(ds.ctx, BucketRef{Name: "photos"}, true))
+	ds.NoError(ds.aa.UsageTrim(ds.ctx, &TrimUsageRequest{UID: UserID{ID: "newuser"}}))
+	ds.NoError(ds.aa.KeyRm(ds.ctx, &KeyRmRequest{AccessKey: "AK", UID: UserID{ID: "newuser"}}))
+
+	// Invalid requests fail as usual, and are not planned.
+	_, err = ds.aa.UserCreate(ds.ctx, &UserCreateRequest{UID: UserID{ID: "bad"}})
+	var ve *ValidationError
+	ds.True(errors.As(err, &ve))
+
+	ds.Equal([]PlanEntry{
+		{"UserCreate", "PUT", "/user", map[string][]string{"uid": {"newuser"}, "display-name": {"New User"}, "email": {"new@example.com"}}},
+		{"QuotaSet", "PUT", "/user?quota", map[string][]string{"uid": {"newuser"}, "quota-type": {"user"}, "max-objects": {"100"}, "enabled": {"true"}}},
+		{"BucketRm", "DELETE", "/bucket", map[string][]string{"bucket": {"photos"}, "purge-objects": {"true"}}},
+		{"UsageTrim", "DELETE", "/usage", map[string][]string{"uid": {"newuser"}}},
+		{"KeyRm", "DELETE", "/user?key", map[string][]string{"access-key": {"AK"}, "uid": {"newuser"}}},
+	}, ds.plan.Entries())
+	ds.Equal("QuotaSet: PUT /user?quota&enabled=true&max-objects=100&quota-type=user&uid=newuser", ds.plan.Entries()[1].String())
+}
+
+func (ds *DryRunSuite) TestSynthesized() {
+	// Reads pass through.
+	uir, err := ds.aa.UserInfo(ds.ctx, UserID{ID: "testuser"}, false)
+	ds.Require().NoError(err)
+	ds.Len(uir.Keys, 4)
+
+	keys, err := ds.aa.KeyCreate(ds.ctx, &KeyCreateRequest{UID: UserID{ID: "testuser"}, AccessKey: "AK", SecretKey: "SK"})
+	ds.Require().NoError(err)
+	ds.Len(keys, 5)
+	ds.Equal(UserKey{User: "testuser", AccessKey: "AK", SecretKey: "SK"}, keys[4])
+
+	caps, err := ds.aa.CapsAdd(ds.ctx, &UserCapsRequest{UID: UserID{ID: "testuser"}, UserCaps: []UserCap{{"users", "read"}, {"buckets", "*"}}})
+	ds.Require().NoError(err)
+	ds.Equal([]UserCap{{"users", "read"}, {"buckets", "*"}}, caps)
+
+	sus, err := ds.aa.SubUserCreate(ds.ctx, &SubUserCreateModifyRequest{UID: UserID{ID: "testuser"}, SubUser: "scully", Access: "readwrite"})
+	ds.Require().NoError(err)
+	ds.Len(sus, 4)
+	ds.Equal(SubUser{ID: "testuser:scully", Permissions: "read-write"}, sus[3])
+
+	mod, err := ds.aa.UserModify(ds.ctx, &UserModifyRequest{UID: UserID{ID: "testuser"}, MaxBuckets: 10, Suspended: TrueRef})
+	ds.Require().NoError(err)
+	ds.Equal(10, mod.MaxBuckets)
+	ds.True(mod.Suspended)
+	ds.Equal("testuser@ena.com", mod.Email)
+
+	// Secret keys are redacted in the plan, however it is printed.
+	entry := ds.plan.Entries()[0]
+	ds.Equal([]string{Redacted}, entry.Params["secret-key"])
+	for _, verb := range []string{"%v", "%+v", "%#v"} {
+		ds.NotContains(fmt.Sprintf(verb, entry), "SK", verb)
+	}
+
+	// Errors from the reads come back like the real call's.
+	_, err = ds.aa.UserModify(ds.ctx, &UserModifyRequest{UID: UserID{ID: "nobody"}, MaxBuckets: 10})
+	ds.True(errors.Is(err, ErrNoSuchUser), "expected NoSuchUser, got %v", err)
+	ds.Len(ds.plan.Entries(), 5)
+}
+
+func (ds *DryRunSuite) TestBucketIndexFix() {
+	// A check is a read, a fix is not.
+	check, err := ds.aa.BucketIndex(ds.ctx, &BucketIndexRequest{Bucket: "pics", CheckObjects: true})
+	ds.Require().NoError(err)
+	ds.Empty(ds.plan.Entries())
+
+	fix, err := ds.aa.BucketIndex(ds.ctx, &BucketIndexRequest{Bucket: "pics", CheckObjects: true, Fix: true})
+	ds.Require().NoError(err)
+	ds.Equal(check, fix)
+	ds.Equal([]PlanEntry{
+		{"BucketIndex", "GET", "/bucket?index", map[string][]string{"bucket": {"pics"}, "check-objects": {"true"}, "fix": {"true"}}},
+	}, ds.plan.Entries())
+}
+
+func (ds *DryRunSuite) TestCaps() {
+	caps := []UserCap{{"users", "*"}, {"buckets", "read"}}
+	added, err := addCaps(caps, []UserCap{{"buckets", "write"}, {"usage", "write"}})
+	ds.Require().NoError(err)
+	removed, err := rmCaps(added, []UserCap{{"users", "write"}})
+	ds.Require().NoError(err)
+	ds.Equal([]UserCap{{"users", "read"}, {"buckets", "*"}, {"usage", "write"}}, removed)
+	removed, err = rmCaps(caps, []UserCap{{"users", "*"}})
+	ds.Require().NoError(err)
+	ds.Equal([]UserCap{{"buckets", "read"}}, removed)
+	_, err = rmCaps(caps, []UserCap{{"users", "rw"}})
+	ds.Error(err)
+}
+
+func (ds *DryRunSuite) TestMiddleware() {
+	aa, err := NewAdminAPI(&Config{ServerURL: ds.gw.URL, AdminPath: "admin", AccessKeyID: "a", SecretAccessKey: "b"})
+	ds.Require().NoError(err)
+	var seen []string
+	mark := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				seen = append(seen, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	aa.Use(mark("before"))
+	dry, _ := DryRun(aa)
+	dry.Use(mark("dry"))
+	aa.Use(mark("after"))
+
+	_, err = dry.UserInfo(ds.ctx, UserID{ID: "testuser"}, false)
+	ds.Require().NoError(err)
+	ds.Equal([]string{"before", "dry"}, seen)
+
+	seen = nil
+	_, err = aa.UserInfo(ds.ctx, UserID{ID: "testuser"}, false)
+	ds.Require().NoError(err)
+	ds.Equal([]string{"before", "after"}, seen)
+}
+
+func TestDryRun(t *testing.T) {
+	suite.Run(t, new(DryRunSuite))
+}
@@ -0,0 +1,155 @@
+package radosgwadmin
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EndpointSelection - how AdminAPI picks between multiple gateways.
+type EndpointSelection string
+
+const (
+	// EndpointRoundRobin - spread requests evenly across all healthy endpoints.
+	// This is the default.
+	EndpointRoundRobin EndpointSelection = "round-robin"
+
+	// EndpointPriority - always use the first healthy endpoint, in the order
+	// they were configured.  The others are only used for failover.
+	EndpointPriority EndpointSelection = "priority"
+)
+
+// DefaultEjectDuration - how long an endpoint is taken out of rotation after a
+// connection error or 5xx response, if Config.EjectDuration is not set.
+const DefaultEjectDuration = 30 * time.Second
+
+// endpoint - one gateway.
+type endpoint struct {
+	server       *url.URL // server url, for the S3 api
+	base         *url.URL // server url + admin path
+	ejectedUntil time.Time
+}
+
+// endpointPool - tracks the health of a set of gateways and hands them out
+// according to the selection policy.
+type endpointPool struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+	selection EndpointSelection
+	eject     time.Duration
+	next      int
+	now       func() time.Time
+}
+
+func newEndpointPool(serverURLs []string, adminPath string, selection EndpointSelection, eject time.Duration) (*endpointPool, error) {
+	switch selection {
+	case "":
+		selection = EndpointRoundRobin
+	case EndpointRoundRobin, EndpointPriority:
+	default:
+		return nil, fmt.Errorf("unknown endpoint selection %q", selection)
+	}
+	if eject <= 0 {
+		eject = DefaultEjectDuration
+	}
+	ep := &endpointPool{
+		selection: selection,
+		eject:     eject,
+		now:       time.Now,
+	}
+	adminPath = strings.Trim(adminPath, "/")
+	for _, su := range serverURLs {
+		if su == "" {
+			continue
+		}
+		su = strings.Trim(su, "/")
+		server, err := url.Parse(su + "/")
+		if err != nil {
+			return nil, err
+		}
+		if adminPath != "" {
+			su += "/" + adminPath
+		}
+		u, err := url.Parse(su)
+		if err != nil {
+			return nil, err
+		}
+		ep.endpoints = append(ep.endpoints, &endpoint{server: server, base: u})
+	}
+	if len(ep.endpoints) == 0 {
+		return nil, fmt.Errorf("no server url specified")
+	}
+	return ep, nil
+}
+
+// primary - the base url requests are built against.  Requests are moved to
+// the selected endpoint by rebase.
+func (ep *endpointPool) primary() *url.URL {
+	return ep.endpoints[0].base
+}
+
+// primaryServer - the server url of the primary endpoint, without the admin
+// path.
+func (ep *endpointPool) primaryServer() *url.URL {
+	return ep.endpoints[0].server
+}
+
+// pick - choose an endpoint, skipping those in the tried set.  Ejected
+// endpoints are only used if nothing else is left, in which case the one
+// due back soonest is returned.  Returns nil once every endpoint has been tried.
+func (ep *endpointPool) pick(tried map[*endpoint]bool) *endpoint {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	now := ep.now()
+	n := len(ep.endpoints)
+	start := 0
+	if ep.selection == EndpointRoundRobin {
+		start = ep.next
+		ep.next = (ep.next + 1) % n
+	}
+	var fallback *endpoint
+	for i := 0; i < n; i++ {
+		e := ep.endpoints[(start+i)%n]
+		if tried[e] {
+			continue
+		}
+		if !e.ejectedUntil.After(now) {
+			return e
+		}
+		if fallback == nil || e.ejectedUntil.Before(fallback.ejectedUntil) {
+			fallback = e
+		}
+	}
+	return fallback
+}
+
+// markFailed - take e out of rotation for the eject duration.
+func (ep *endpointPool) markFailed(e *endpoint) {
+	ep.mu.Lock()
+	e.ejectedUntil = ep.now().Add(ep.eject)
+	ep.mu.Unlock()
+}
+
+// markHealthy - put e back into rotation.
+func (ep *endpointPool) markHealthy(e *endpoint) {
+	ep.mu.Lock()
+	e.ejectedUntil = time.Time{}
+	ep.mu.Unlock()
+}
+
+// rebase - point u, which was built against the primary endpoint, at e.
+func (ep *endpointPool) rebase(u *url.URL, e *endpoint) {
+	p := ep.primary()
+	if e.base == p {
+		return
+	}
+	u.Scheme = e.base.Scheme
+	u.Host = e.base.Host
+	u.User = e.base.User
+	if rest := strings.TrimPrefix(u.Path, p.Path); rest != u.Path {
+		u.Path = e.base.Path + rest
+		u.RawPath = ""
+	}
+}
@@ -0,0 +1,116 @@
+package radosgwadmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// RGWError - error returned by the rados gateway for any response with a status
+// code of 400 or higher.  The gateway's JSON error body is decoded into Code,
+// Message, RequestID and HostID where present, the raw body is kept in Body.
+//
+// Use errors.Is with one of the Err* sentinels to test for a specific code,
+// or errors.As to get at the details.
+type RGWError struct {
+	StatusCode int    `json:"-"`
+	Status     string `json:"-"`
+	Code       string `json:"Code"`
+	Message    string `json:"Message"`
+	RequestID  string `json:"RequestId"`
+	HostID     string `json:"HostId"`
+	Body       []byte `json:"-"`
+
+	resp *http.Response
+}
+
+// Error - implements error
+func (e *RGWError) Error() string {
+	var b strings.Builder
+	b.WriteString("rgw: ")
+	if e.Code != "" {
+		b.WriteString(e.Code)
+	} else {
+		b.WriteString(http.StatusText(e.StatusCode))
+	}
+	fmt.Fprintf(&b, " (status %d", e.StatusCode)
+	if e.RequestID != "" {
+		b.WriteString(", request id ")
+		b.WriteString(e.RequestID)
+	}
+	b.WriteString(")")
+	if e.Message != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	} else if e.Code == "" && len(e.Body) > 0 {
+		b.WriteString(": ")
+		b.Write(e.Body)
+	}
+	return b.String()
+}
+
+// Is - lets errors.Is match an *RGWError against the Err* sentinels by code.
+func (e *RGWError) Is(target error) bool {
+	c, ok := target.(errorCode)
+	return ok && string(c) == e.Code
+}
+
+// errorCode - sentinel error type, one per rgw error code.
+type errorCode string
+
+func (c errorCode) Error() string {
+	return "rgw: " + string(c)
+}
+
+// Sentinel errors for the error codes returned by the rados gateway.  These
+// are meant to be used with errors.Is, for example:
+//
+//	_, err := aa.UserInfo(ctx, UserID{ID: "nobody"}, false)
+//	if errors.Is(err, radosgwadmin.ErrNoSuchUser) {
+//	    // create it
+//	}
+var (
+	ErrAccessDenied          error = errorCode("AccessDenied")
+	ErrBucketAlreadyExists   error = errorCode("BucketAlreadyExists")
+	ErrBucketNotEmpty        error = errorCode("BucketNotEmpty")
+	ErrEmailExists           error = errorCode("EmailExists")
+	ErrInternalError         error = errorCode("InternalError")
+	ErrInvalidAccess         error = errorCode("InvalidAccess")
+	ErrInvalidAccessKeyID    error = errorCode("InvalidAccessKeyId")
+	ErrInvalidArgument       error = errorCode("InvalidArgument")
+	ErrInvalidBucketName     error = errorCode("InvalidBucketName")
+	ErrInvalidCapability     error = errorCode("InvalidCapability")
+	ErrInvalidKeyType        error = errorCode("InvalidKeyType")
+	ErrInvalidSecretKey      error = errorCode("InvalidSecretKey")
+	ErrKeyExists             error = errorCode("KeyExists")
+	ErrNoSuchBucket          error = errorCode("NoSuchBucket")
+	ErrNoSuchKey             error = errorCode("NoSuchKey")
+	ErrNoSuchSubUser         error = errorCode("NoSuchSubUser")
+	ErrNoSuchUser            error = errorCode("NoSuchUser")
+	ErrQuotaExceeded         error = errorCode("QuotaExceeded")
+	ErrSignatureDoesNotMatch error = errorCode("SignatureDoesNotMatch")
+	ErrSlowDown              error = errorCode("SlowDown")
+	ErrSubUserExists         error = errorCode("SubuserExists")
+	ErrUserAlreadyExists     error = errorCode("UserAlreadyExists")
+	ErrUserSuspended         error = errorCode("UserSuspended")
+)
+
+// decodeErrorResponse - restclient.ErrorResponseCallback that turns an error
+// response into an *RGWError.
+func decodeErrorResponse(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	rerr := &RGWError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       body,
+	}
+	// Not every error comes with a json body (a proxy in front of the gateway,
+	// for example), in which case only the status is filled in.
+	_ = json.Unmarshal(body, rerr)
+	if rerr.RequestID == "" {
+		rerr.RequestID = resp.Header.Get("X-Amz-Request-Id")
+	}
+	return rerr
+}
@@ -0,0 +1,30 @@
+package radosgwadmin_test
+
+import (
+	"context"
+
+	rgw "github.com/myENA/radosgwadmin"
+	"github.com/myENA/radosgwadmin/radosgwadmintest"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeSuite - the fixture shared by the suites that test against the fake
+// gateway: a new server for every test, and an AdminAPI for its admin user.
+type fakeSuite struct {
+	suite.Suite
+	ctx context.Context
+	srv *radosgwadmintest.Server
+	aa  *rgw.AdminAPI
+}
+
+func (fs *fakeSuite) SetupTest() {
+	fs.ctx = context.Background()
+	fs.srv = radosgwadmintest.NewServer()
+	var err error
+	fs.aa, err = rgw.NewAdminAPI(fs.srv.Config())
+	fs.Require().NoError(err)
+}
+
+func (fs *fakeSuite) TearDownTest() {
+	fs.srv.Close()
+}
@@ -0,0 +1,109 @@
+package radosgwadmin
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// UserID - identifies a user, optionally in a tenant.  The gateway writes it as
+// "tenant$id", or just "id" for users outside any tenant.
+type UserID struct {
+	Tenant string
+	ID     string
+}
+
+// ParseUserID - parse "tenant$id" or "id".  The id must not be empty.
+func ParseUserID(s string) (UserID, error) {
+	var u UserID
+	return u, u.UnmarshalText([]byte(s))
+}
+
+// String - implements fmt.Stringer, in the gateway's "tenant$id" form.
+func (u UserID) String() string {
+	if u.Tenant == "" {
+		return u.ID
+	}
+	return u.Tenant + "$" + u.ID
+}
+
+// IsZero - true if neither the tenant nor the id is set.
+func (u UserID) IsZero() bool {
+	return u == UserID{}
+}
+
+// MarshalText - implements encoding.TextMarshaler
+func (u UserID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText - implements encoding.TextUnmarshaler
+func (u *UserID) UnmarshalText(text []byte) error {
+	s := string(text)
+	*u = UserID{ID: s}
+	if i := strings.Index(s, "$"); i >= 0 {
+		*u = UserID{Tenant: s[:i], ID: s[i+1:]}
+	}
+	if u.ID == "" {
+		return fmt.Errorf("radosgwadmin: empty user id in %q", s)
+	}
+	return nil
+}
+
+// EncodeValues - implements query.Encoder, so that request structs send the
+// user id in the gateway's "tenant$id" form.
+func (u UserID) EncodeValues(key string, v *url.Values) error {
+	v.Set(key, u.String())
+	return nil
+}
+
+// BucketRef - identifies a bucket, optionally in a tenant.  The gateway writes
+// it as "tenant/name", or just "name" for buckets outside any tenant.
+type BucketRef struct {
+	Tenant string
+	Name   string
+}
+
+// ParseBucketRef - parse "tenant/name" or "name".  The name must not be empty.
+func ParseBucketRef(s string) (BucketRef, error) {
+	var b BucketRef
+	return b, b.UnmarshalText([]byte(s))
+}
+
+// String - implements fmt.Stringer, in the gateway's "tenant/name" form.
+func (b BucketRef) String() string {
+	if b.Tenant == "" {
+		return b.Name
+	}
+	return b.Tenant + "/" + b.Name
+}
+
+// IsZero - true if neither the tenant nor the name is set.
+func (b BucketRef) IsZero() bool {
+	return b == BucketRef{}
+}
+
+// MarshalText - implements encoding.TextMarshaler
+func (b BucketRef) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// EncodeValues - implements query.Encoder, so that request structs send the
+// bucket in the gateway's "tenant/name" form.
+func (b BucketRef) EncodeValues(key string, v *url.Values) error {
+	v.Set(key, b.String())
+	return nil
+}
+
+// UnmarshalText - implements encoding.TextUnmarshaler
+func (b *BucketRef) UnmarshalText(text []byte) error {
+	s := string(text)
+	*b = BucketRef{Name: s}
+	if i := strings.Index(s, "/"); i >= 0 {
+		*b = BucketRef{Tenant: s[:i], Name: s[i+1:]}
+	}
+	if b.Name == "" {
+		return fmt.Errorf("radosgwadmin: empty bucket name in %q", s)
+	}
+	return nil
+}
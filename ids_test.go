@@ -0,0 +1,86 @@
+package radosgwadmin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-querystring/query"
+	"github.com/stretchr/testify/suite"
+)
+
+type IDsSuite struct {
+	suite.Suite
+}
+
+func (is *IDsSuite) TestUserID() {
+	for s, want := range map[string]UserID{
+		"alice":       {ID: "alice"},
+		"acme$alice":  {Tenant: "acme", ID: "alice"},
+		"$alice":      {ID: "alice"},
+		"acme$a$lice": {Tenant: "acme", ID: "a$lice"},
+	} {
+		u, err := ParseUserID(s)
+		is.NoError(err, s)
+		is.Equal(want, u, s)
+	}
+	is.Equal("acme$alice", UserID{Tenant: "acme", ID: "alice"}.String())
+	is.Equal("alice", UserID{ID: "alice"}.String())
+	is.True(UserID{}.IsZero())
+
+	for _, s := range []string{"", "acme$"} {
+		_, err := ParseUserID(s)
+		is.Error(err, s)
+	}
+}
+
+func (is *IDsSuite) TestBucketRef() {
+	for s, want := range map[string]BucketRef{
+		"photos":      {Name: "photos"},
+		"acme/photos": {Tenant: "acme", Name: "photos"},
+	} {
+		b, err := ParseBucketRef(s)
+		is.NoError(err, s)
+		is.Equal(want, b, s)
+	}
+	is.Equal("acme/photos", BucketRef{Tenant: "acme", Name: "photos"}.String())
+	is.True(BucketRef{}.IsZero())
+
+	for _, s := range []string{"", "acme/"} {
+		_, err := ParseBucketRef(s)
+		is.Error(err, s)
+	}
+}
+
+func (is *IDsSuite) TestText() {
+	var v struct {
+		Owner  UserID
+		Bucket BucketRef
+	}
+	is.Require().NoError(json.Unmarshal([]byte(`{"Owner":"acme$alice","Bucket":"acme/photos"}`), &v))
+	is.Equal(UserID{"acme", "alice"}, v.Owner)
+	is.Equal(BucketRef{"acme", "photos"}, v.Bucket)
+	data, err := json.Marshal(v)
+	is.NoError(err)
+	is.JSONEq(`{"Owner":"acme$alice","Bucket":"acme/photos"}`, string(data))
+}
+
+func (is *IDsSuite) TestQuery() {
+	q, err := query.Values(&KeyRmRequest{UID: UserID{"acme", "alice"}, AccessKey: "AK"})
+	is.Require().NoError(err)
+	is.Equal("access-key=AK&uid=acme%24alice", q.Encode())
+
+	q, err = query.Values(&KeyRmRequest{AccessKey: "AK"})
+	is.Require().NoError(err)
+	is.Equal("access-key=AK", q.Encode())
+
+	caps := []UserCap{{"users", "read"}}
+	err = validateRequest("CapsAdd", &UserCapsRequest{UserCaps: caps})
+	var ve *ValidationError
+	is.Require().ErrorAs(err, &ve)
+	is.Equal([]FieldError{{Field: "UID", Rule: "required", Value: ""}}, ve.Fields)
+	is.NoError(validateRequest("CapsAdd", &UserCapsRequest{UID: UserID{ID: "alice"}, UserCaps: caps}))
+}
+
+func TestIDs(t *testing.T) {
+	suite.Run(t, new(IDsSuite))
+}
@@ -2,11 +2,13 @@ package radosgwadmin
 
 import (
 	"context"
+	"sort"
+	"time"
 )
 
 // KeyCreateRequest - Create or modify a key.
 type KeyCreateRequest struct {
-	UID         string `url:"uid" validate:"required"`
+	UID         UserID `url:"uid" validate:"required"`
 	SubUser     string `url:"subuser,omitempty"`
 	AccessKey   string `url:"access-key,omitempty"`
 	SecretKey   string `url:"secret-key,omitempty"`
@@ -17,11 +19,32 @@ type KeyCreateRequest struct {
 // KeyRmRequest - Create or modify a key.
 type KeyRmRequest struct {
 	AccessKey string `url:"access-key" validate:"required"`
-	UID       string `url:"uid,omitempty"`
+	UID       UserID `url:"uid,omitempty"`
 	SubUser   string `url:"subuser,omitempty"`
 	KeyType   string `url:"key-type,omitempty" validate:"omitempty,eq=s3|eq=swift"`
 }
 
+// KeyModifyRequest - Activate or deactivate a key.  S3 keys are picked by
+// AccessKey, swift keys by SubUser.  Active is required, TrueRef or FalseRef.
+type KeyModifyRequest struct {
+	UID       UserID `url:"uid" validate:"required"`
+	AccessKey string `url:"access-key,omitempty" validate:"required_without=SubUser"`
+	SubUser   string `url:"subuser,omitempty"`
+	KeyType   string `url:"key-type,omitempty" validate:"omitempty,eq=s3|eq=swift"`
+	Active    *bool  `url:"active,omitempty" validate:"required"`
+}
+
+// KeyAge - a key found by KeysOlderThan.
+type KeyAge struct {
+	UID        UserID
+	User       string // the user or subuser the key belongs to
+	KeyType    string // s3 or swift
+	AccessKey  string // empty for swift keys
+	Active     bool
+	CreateDate time.Time
+	Age        time.Duration
+}
+
 // KeyCreate - Create a key
 //
 // Create a new key. If a subuser is specified then by default created keys will
@@ -35,7 +58,7 @@ type KeyRmRequest struct {
 // effect. Additionally, only one swift key may be held by each user or subuser.
 func (aa *AdminAPI) KeyCreate(ctx context.Context, kcr *KeyCreateRequest) ([]UserKey, error) {
 	resp := []UserKey{}
-	err := aa.Put(ctx, "/user?key", kcr, nil, &resp)
+	err := aa.put(ctx, "KeyCreate", "/user?key", kcr, &resp)
 	return resp, err
 }
 
@@ -43,5 +66,62 @@ func (aa *AdminAPI) KeyCreate(ctx context.Context, kcr *KeyCreateRequest) ([]Use
 //
 // Key type is optional, but required to remove a swift key.
 func (aa *AdminAPI) KeyRm(ctx context.Context, krr *KeyRmRequest) error {
-	return aa.Delete(ctx, "/user?key", krr, nil)
+	return aa.delete(ctx, "KeyRm", "/user?key", krr, nil)
+}
+
+// KeyModify - activate or deactivate a key, with Active.  An inactive key stays
+// with the user but no longer authenticates.  Only newer releases support this.
+//
+// The response lists all keys of the same type as the key modified.
+func (aa *AdminAPI) KeyModify(ctx context.Context, kmr *KeyModifyRequest) ([]UserKey, error) {
+	resp := []UserKey{}
+	err := aa.post(ctx, "KeyModify", "/user?key", kmr, &resp)
+	return resp, err
+}
+
+// KeysOlderThan - the s3 and swift keys of all users created more than days days
+// ago, oldest first.  Keys without a creation date, from releases that don't
+// record it, are left out.  Every user's metadata is read, as UserInfoByEmail
+// does when the gateway can't look up emails.
+func (aa *AdminAPI) KeysOlderThan(ctx context.Context, days int) ([]KeyAge, error) {
+	now := timeNow()
+	cutoff := now.AddDate(0, 0, -days)
+	ages := []KeyAge{}
+	add := func(uid UserID, user, keyType, accessKey string, active bool, created *RadosTime) {
+		if created == nil || !time.Time(*created).Before(cutoff) {
+			return
+		}
+		t := time.Time(*created)
+		ages = append(ages, KeyAge{
+			UID:        uid,
+			User:       user,
+			KeyType:    keyType,
+			AccessKey:  accessKey,
+			Active:     active,
+			CreateDate: t,
+			Age:        now.Sub(t),
+		})
+	}
+	err := aa.scanUsers(ctx, func(uid UserID, mur *MUserResponse) bool {
+		for _, k := range mur.Data.Keys {
+			add(uid, k.User, "s3", k.AccessKey, k.IsActive(), k.CreateDate)
+		}
+		for _, k := range mur.Data.SwiftKeys {
+			add(uid, k.User, "swift", "", k.IsActive(), k.CreateDate)
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ages, func(i, j int) bool {
+		if !ages[i].CreateDate.Equal(ages[j].CreateDate) {
+			return ages[i].CreateDate.Before(ages[j].CreateDate)
+		}
+		if ages[i].User != ages[j].User {
+			return ages[i].User < ages[j].User
+		}
+		return ages[i].AccessKey < ages[j].AccessKey
+	})
+	return ages, nil
 }
@@ -0,0 +1,99 @@
+package radosgwadmin_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	rgw "github.com/myENA/radosgwadmin"
+	"github.com/stretchr/testify/suite"
+)
+
+type KeySuite struct {
+	fakeSuite
+}
+
+func (ks *KeySuite) create(uid string) rgw.UserKey {
+	ui, err := ks.aa.UserCreate(ks.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: uid}, DisplayName: uid})
+	ks.Require().NoError(err)
+	ks.Require().Len(ui.Keys, 1)
+	return ui.Keys[0]
+}
+
+func (ks *KeySuite) TestDecode() {
+	k := rgw.UserKey{}
+	ks.Require().NoError(json.Unmarshal([]byte(`{"user":"alice","access_key":"A","secret_key":"S",`+
+		`"active":false,"create_date":"2024-03-11T09:12:44.214377Z"}`), &k))
+	ks.False(k.IsActive())
+	ks.Equal(time.Date(2024, 3, 11, 9, 12, 44, 214377000, time.UTC), time.Time(*k.CreateDate).UTC())
+
+	k = rgw.UserKey{}
+	ks.Require().NoError(json.Unmarshal([]byte(`{"user":"alice","access_key":"A","secret_key":"S"}`), &k))
+	ks.True(k.IsActive(), "keys from releases without the flag are active")
+	ks.Nil(k.CreateDate)
+}
+
+func (ks *KeySuite) TestModify() {
+	key := ks.create("erin")
+	ks.True(key.IsActive())
+	ks.Require().NotNil(key.CreateDate)
+
+	cfg := ks.srv.Config()
+	cfg.AccessKeyID, cfg.SecretAccessKey = key.AccessKey, key.SecretKey
+	erin, err := rgw.NewAdminAPI(cfg)
+	ks.Require().NoError(err)
+	_, err = erin.UserInfo(ks.ctx, rgw.UserID{ID: "erin"}, false)
+	ks.False(errors.Is(err, rgw.ErrInvalidAccessKeyID), "got %v", err)
+
+	keys, err := ks.aa.KeyModify(ks.ctx, &rgw.KeyModifyRequest{UID: rgw.UserID{ID: "erin"}, AccessKey: key.AccessKey, Active: rgw.FalseRef})
+	ks.Require().NoError(err)
+	ks.Require().Len(keys, 1)
+	ks.False(keys[0].IsActive())
+	_, err = erin.UserInfo(ks.ctx, rgw.UserID{ID: "erin"}, false)
+	ks.True(errors.Is(err, rgw.ErrInvalidAccessKeyID), "got %v", err)
+
+	keys, err = ks.aa.KeyModify(ks.ctx, &rgw.KeyModifyRequest{UID: rgw.UserID{ID: "erin"}, AccessKey: key.AccessKey, Active: rgw.TrueRef})
+	ks.Require().NoError(err)
+	ks.True(keys[0].IsActive())
+
+	_, err = ks.aa.KeyModify(ks.ctx, &rgw.KeyModifyRequest{UID: rgw.UserID{ID: "erin"}, Active: rgw.FalseRef})
+	ks.Error(err, "an access key or subuser is required")
+
+	_, err = ks.aa.KeyModify(ks.ctx, &rgw.KeyModifyRequest{UID: rgw.UserID{ID: "erin"}, AccessKey: key.AccessKey})
+	var ve *rgw.ValidationError
+	ks.Require().ErrorAs(err, &ve)
+	ks.Require().Len(ve.Fields, 1)
+	ks.Equal("Active", ve.Fields[0].Field)
+	ks.Equal("required", ve.Fields[0].Rule)
+}
+
+func (ks *KeySuite) TestOlderThan() {
+	now := time.Now()
+	old := ks.create("frank")
+	older := ks.create("gina")
+	ks.create("hank")
+	ks.Require().NoError(ks.srv.SetKeyCreated(old.AccessKey, now.AddDate(0, 0, -100)))
+	ks.Require().NoError(ks.srv.SetKeyCreated(older.AccessKey, now.AddDate(0, 0, -400)))
+	_, err := ks.aa.KeyModify(ks.ctx, &rgw.KeyModifyRequest{UID: rgw.UserID{ID: "gina"}, AccessKey: older.AccessKey, Active: rgw.FalseRef})
+	ks.Require().NoError(err)
+
+	ages, err := ks.aa.KeysOlderThan(ks.ctx, 90)
+	ks.Require().NoError(err)
+	ks.Require().Len(ages, 2)
+	ks.Equal(rgw.UserID{ID: "gina"}, ages[0].UID)
+	ks.Equal(older.AccessKey, ages[0].AccessKey)
+	ks.Equal("s3", ages[0].KeyType)
+	ks.False(ages[0].Active)
+	ks.InDelta(float64(400*24*time.Hour), float64(ages[0].Age), float64(time.Hour))
+	ks.Equal(old.AccessKey, ages[1].AccessKey)
+	ks.True(ages[1].Active)
+
+	ages, err = ks.aa.KeysOlderThan(ks.ctx, 365)
+	ks.Require().NoError(err)
+	ks.Len(ages, 1)
+}
+
+func TestKeys(t *testing.T) {
+	suite.Run(t, new(KeySuite))
+}
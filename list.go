@@ -0,0 +1,138 @@
+package radosgwadmin
+
+import (
+	"context"
+)
+
+// DefaultListPageSize - default for Config.ListPageSize.
+const DefaultListPageSize = 1000
+
+type metaListRequest struct {
+	MaxEntries int    `url:"max-entries" validate:"min=1"`
+	Marker     string `url:"marker,omitempty"`
+}
+
+// MListPage - one page of a metadata listing.  If Truncated is set, pass Marker
+// to get the next page.
+type MListPage struct {
+	Keys      []string `json:"keys"`
+	Truncated bool     `json:"truncated"`
+	Count     int      `json:"count"`
+	Marker    string   `json:"marker"`
+}
+
+// MListUsersPage - like MListUsers, but returns up to maxEntries users, starting
+// after marker.  Pass an empty marker for the first page.
+func (aa *AdminAPI) MListUsersPage(ctx context.Context, marker string, maxEntries int) (*MListPage, error) {
+	return aa.mListPage(ctx, "MListUsersPage", "/metadata/user", marker, maxEntries)
+}
+
+// MListBucketsPage - like MListBuckets, but returns up to maxEntries buckets,
+// starting after marker.  Pass an empty marker for the first page.
+func (aa *AdminAPI) MListBucketsPage(ctx context.Context, marker string, maxEntries int) (*MListPage, error) {
+	return aa.mListPage(ctx, "MListBucketsPage", "/metadata/bucket", marker, maxEntries)
+}
+
+// MListBucketInstancesPage - like MListBucketInstances, but returns up to
+// maxEntries bucket instances, starting after marker.  Pass an empty marker for
+// the first page.
+func (aa *AdminAPI) MListBucketInstancesPage(ctx context.Context, marker string, maxEntries int) (*MListPage, error) {
+	return aa.mListPage(ctx, "MListBucketInstancesPage", "/metadata/bucket.instance", marker, maxEntries)
+}
+
+func (aa *AdminAPI) mListPage(ctx context.Context, op, path, marker string, maxEntries int) (*MListPage, error) {
+	req := &metaListRequest{MaxEntries: maxEntries, Marker: marker}
+	resp := &MListPage{}
+	err := aa.get(ctx, op, path, req, resp)
+	return resp, err
+}
+
+// ListIterator - iterates over a listing a page at a time, fetching the next
+// page as needed.  Use it like bufio.Scanner:
+//
+//	it := aa.Users(ctx)
+//	for it.Next() {
+//	    fmt.Println(it.Value())
+//	}
+//	if err := it.Err(); err != nil {
+//	    // handle error
+//	}
+type ListIterator struct {
+	ctx   context.Context
+	fetch func(ctx context.Context, marker string) (*MListPage, error)
+
+	page  []string
+	value string
+	more  bool
+	next  string
+	err   error
+}
+
+func newListIterator(ctx context.Context, fetch func(ctx context.Context, marker string) (*MListPage, error)) *ListIterator {
+	return &ListIterator{ctx: ctx, fetch: fetch, more: true}
+}
+
+// Next - advance to the next value, fetching a page if need be.  It returns
+// false at the end of the listing, or on error, see Err.
+func (it *ListIterator) Next() bool {
+	for len(it.page) == 0 {
+		if !it.more || it.err != nil {
+			return false
+		}
+		p, err := it.fetch(it.ctx, it.next)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page = p.Keys
+		// A truncated page should always come with a marker, don't loop forever
+		// if it doesn't.
+		it.more = p.Truncated && p.Marker != "" && p.Marker != it.next
+		it.next = p.Marker
+	}
+	it.value, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Value - the current value.
+func (it *ListIterator) Value() string {
+	return it.value
+}
+
+// Err - the error that stopped the iteration, if any.
+func (it *ListIterator) Err() error {
+	return it.err
+}
+
+// pager - fetch func for a paged metadata listing.
+func (aa *AdminAPI) pager(page func(context.Context, string, int) (*MListPage, error)) func(context.Context, string) (*MListPage, error) {
+	return func(ctx context.Context, marker string) (*MListPage, error) {
+		return page(ctx, marker, aa.listPageSize)
+	}
+}
+
+// Users - iterate over all user ids, in the "tenant$id" form, Config.ListPageSize
+// at a time.
+func (aa *AdminAPI) Users(ctx context.Context) *ListIterator {
+	return newListIterator(ctx, aa.pager(aa.MListUsersPage))
+}
+
+// Buckets - iterate over bucket names, in the "tenant/name" form.  If uid is
+// zero, these are all the buckets, Config.ListPageSize at a time.  Otherwise
+// they are the user's buckets, which the gateway can't page, and come from a
+// single BucketList call.  A user's buckets are limited by their max_buckets.
+func (aa *AdminAPI) Buckets(ctx context.Context, uid UserID) *ListIterator {
+	if uid.IsZero() {
+		return newListIterator(ctx, aa.pager(aa.MListBucketsPage))
+	}
+	return newListIterator(ctx, func(ctx context.Context, marker string) (*MListPage, error) {
+		names, err := aa.BucketList(ctx, uid)
+		return &MListPage{Keys: names, Count: len(names)}, err
+	})
+}
+
+// BucketInstances - iterate over all bucket instances, in the "name:bucket_id"
+// form, Config.ListPageSize at a time.
+func (aa *AdminAPI) BucketInstances(ctx context.Context) *ListIterator {
+	return newListIterator(ctx, aa.pager(aa.MListBucketInstancesPage))
+}
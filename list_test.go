@@ -0,0 +1,129 @@
+package radosgwadmin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ListSuite struct {
+	suite.Suite
+	ctx      context.Context
+	gw       *httptest.Server
+	aa       *AdminAPI
+	keys     []string
+	requests []string
+	failAt   string // marker to fail at
+}
+
+func (ls *ListSuite) SetupTest() {
+	ls.ctx = context.Background()
+	ls.keys = nil
+	for i := 0; i < 25; i++ {
+		ls.keys = append(ls.keys, fmt.Sprintf("user%02d", i))
+	}
+	ls.requests = nil
+	ls.failAt = "-"
+	ls.gw = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		ls.requests = append(ls.requests, r.URL.Path+"?"+q.Encode())
+		if r.URL.Path == "/admin/bucket" {
+			fmt.Fprint(w, `["b1","b2"]`)
+			return
+		}
+		if q.Get("marker") == ls.failAt {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"Code":"SlowDown"}`)
+			return
+		}
+		max, _ := strconv.Atoi(q.Get("max-entries"))
+		i := sort.SearchStrings(ls.keys, q.Get("marker"))
+		if i < len(ls.keys) && ls.keys[i] == q.Get("marker") {
+			i++
+		}
+		page := MListPage{Keys: ls.keys[i:]}
+		if len(page.Keys) > max {
+			page.Keys = page.Keys[:max]
+			page.Truncated = true
+			page.Marker = page.Keys[max-1]
+		}
+		page.Count = len(page.Keys)
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	var err error
+	ls.aa, err = NewAdminAPI(&Config{
+		ServerURL:       ls.gw.URL,
+		AdminPath:       "admin",
+		AccessKeyID:     "a",
+		SecretAccessKey: "b",
+		ListPageSize:    10,
+		Retry:           RetryPolicy{MaxAttempts: 1},
+	})
+	ls.Require().NoError(err)
+}
+
+func (ls *ListSuite) TearDownTest() {
+	ls.gw.Close()
+}
+
+func (ls *ListSuite) TestUsers() {
+	var got []string
+	it := ls.aa.Users(ls.ctx)
+	for it.Next() {
+		got = append(got, it.Value())
+	}
+	ls.NoError(it.Err())
+	ls.Equal(ls.keys, got)
+	ls.Equal([]string{
+		"/admin/metadata/user?max-entries=10",
+		"/admin/metadata/user?marker=user09&max-entries=10",
+		"/admin/metadata/user?marker=user19&max-entries=10",
+	}, ls.requests)
+	ls.False(it.Next(), "an exhausted iterator stays exhausted")
+	ls.Len(ls.requests, 3)
+}
+
+func (ls *ListSuite) TestPage() {
+	page, err := ls.aa.MListBucketInstancesPage(ls.ctx, "user20", 10)
+	ls.Require().NoError(err)
+	ls.Equal(&MListPage{Keys: []string{"user21", "user22", "user23", "user24"}, Count: 4}, page)
+
+	_, err = ls.aa.MListBucketsPage(ls.ctx, "", 0)
+	var ve *ValidationError
+	ls.True(errors.As(err, &ve), "got %v", err)
+}
+
+func (ls *ListSuite) TestError() {
+	ls.failAt = "user19"
+	n := 0
+	it := ls.aa.BucketInstances(ls.ctx)
+	for it.Next() {
+		n++
+	}
+	ls.Equal(20, n)
+	ls.True(errors.Is(it.Err(), ErrSlowDown), "got %v", it.Err())
+	ls.False(it.Next())
+}
+
+func (ls *ListSuite) TestUserBuckets() {
+	var got []string
+	it := ls.aa.Buckets(ls.ctx, UserID{ID: "someone"})
+	for it.Next() {
+		got = append(got, it.Value())
+	}
+	ls.NoError(it.Err())
+	ls.Equal([]string{"b1", "b2"}, got)
+	ls.Equal([]string{"/admin/bucket?uid=someone"}, ls.requests)
+}
+
+func TestList(t *testing.T) {
+	suite.Run(t, new(ListSuite))
+}
@@ -2,6 +2,7 @@ package radosgwadmin
 
 import (
 	"context"
+	"encoding/json"
 )
 
 type metaReq struct {
@@ -18,6 +19,11 @@ type MetaResponse struct {
 	} `json:"ver"`
 }
 
+// meta - the MetaResponse of any metadata response, for locDecoder.
+func (mr *MetaResponse) meta() *MetaResponse {
+	return mr
+}
+
 // MBucketInstanceResponse - response from bucket.instance get
 type MBucketInstanceResponse struct {
 	MetaResponse
@@ -79,55 +85,71 @@ type MUserResponse struct {
 }
 
 // MListUsers - This is the radosgw-admin metadata list user command
-// Returns a list of usernames
+// Returns a list of usernames, all in one response.  On large clusters use
+// Users instead.
 func (aa *AdminAPI) MListUsers(ctx context.Context) ([]string, error) {
 	resp := []string{}
-	err := aa.Get(ctx, "/metadata/user", nil, &resp)
+	err := aa.get(ctx, "MListUsers", "/metadata/user", nil, &resp)
 	return resp, err
 }
 
 // MGetUser - This is the radosgw-admin metadata get user command
 // Returns metadata about a single user
-func (aa *AdminAPI) MGetUser(ctx context.Context, user string) (*MUserResponse, error) {
-	mr := &metaReq{user}
+func (aa *AdminAPI) MGetUser(ctx context.Context, user UserID) (*MUserResponse, error) {
+	mr := &metaReq{user.String()}
 	resp := &MUserResponse{}
 
-	err := aa.Get(ctx, "metadata/user", mr, resp)
+	err := aa.get(ctx, "MGetUser", "metadata/user", mr, resp)
 	return resp, err
 }
 
+// MPutUser - This is the radosgw-admin metadata put user command
+// Replaces the user's metadata with mur, usually what MGetUser returned with
+// some changes made.  The keys are sent as they are, not redacted as
+// json.Marshal would have them.
+func (aa *AdminAPI) MPutUser(ctx context.Context, user UserID, mur *MUserResponse) error {
+	body, err := MarshalSecrets(mur)
+	if err != nil {
+		return err
+	}
+	mr := &metaReq{user.String()}
+	return aa.putBody(ctx, "MPutUser", "metadata/user", mr, json.RawMessage(body), nil)
+}
+
 // MListBuckets - This is the "radosgw-admin metadata list bucket" command
-// Returns a list of usernames
+// Returns a list of bucket names, all in one response.  On large clusters use
+// Buckets instead.
 func (aa *AdminAPI) MListBuckets(ctx context.Context) ([]string, error) {
 	resp := []string{}
-	err := aa.Get(ctx, "/metadata/bucket", nil, &resp)
+	err := aa.get(ctx, "MListBuckets", "/metadata/bucket", nil, &resp)
 	return resp, err
 }
 
 // MGetBucket - This is the radosgw-admin metadata get bucket command
 // Returns metadata about a single bucket
-func (aa *AdminAPI) MGetBucket(ctx context.Context, bucket string) (*MBucketResponse, error) {
-	mr := &metaReq{bucket}
+func (aa *AdminAPI) MGetBucket(ctx context.Context, bucket BucketRef) (*MBucketResponse, error) {
+	mr := &metaReq{bucket.String()}
 	resp := &MBucketResponse{}
 
-	err := aa.Get(ctx, "metadata/bucket", mr, resp)
+	err := aa.get(ctx, "MGetBucket", "metadata/bucket", mr, resp)
 	return resp, err
 }
 
 // MListBucketInstances - This is the "radosgw-admin metadata list bucket.instance" command
-// Returns a list of usernames
+// Returns a list of bucket instances, all in one response.  On large clusters
+// use BucketInstances instead.
 func (aa *AdminAPI) MListBucketInstances(ctx context.Context) ([]string, error) {
 	resp := []string{}
-	err := aa.Get(ctx, "/metadata/bucket.instance", nil, &resp)
+	err := aa.get(ctx, "MListBucketInstances", "/metadata/bucket.instance", nil, &resp)
 	return resp, err
 }
 
 // MGetBucketInstance - This is the radosgw-admin metadata get bucket.instance command
-// Returns metadata about a single bucket.instance
-func (aa *AdminAPI) MGetBucketInstance(ctx context.Context, bucket string) (*MBucketInstanceResponse, error) {
-	mr := &metaReq{bucket}
+// Returns metadata about a single instance, bucketID, of bucket.
+func (aa *AdminAPI) MGetBucketInstance(ctx context.Context, bucket BucketRef, bucketID string) (*MBucketInstanceResponse, error) {
+	mr := &metaReq{bucket.String() + ":" + bucketID}
 	resp := &MBucketInstanceResponse{}
 
-	err := aa.Get(ctx, "metadata/bucket.instance", mr, resp)
+	err := aa.get(ctx, "MGetBucketInstance", "metadata/bucket.instance", mr, resp)
 	return resp, err
 }
@@ -0,0 +1,70 @@
+package radosgwadmin
+
+import (
+	"errors"
+	"time"
+)
+
+// MetricsObserver - receives one CallObservation at the end of every operation.
+// It is called concurrently and must not block.  The rgwprom sub-package has an
+// implementation that is also a prometheus.Collector.
+type MetricsObserver interface {
+	ObserveCall(obs CallObservation)
+}
+
+// Error codes reported in CallObservation for errors that do not carry a code
+// from the gateway.
+const (
+	// ErrorCodeUnknown - the gateway returned an error response without a code,
+	// e.g. a proxy in front of it returned an html error page.
+	ErrorCodeUnknown = "Unknown"
+	// ErrorCodeClient - no response was received, because the gateway could not
+	// be reached, the context was done, or the request was never sent.
+	ErrorCodeClient = "ClientError"
+)
+
+// CallObservation - the outcome of one operation, retries and failover included.
+type CallObservation struct {
+	// Operation - name of the AdminAPI method, e.g. "UserInfo".
+	Operation string
+	// Method - http method.
+	Method string
+	// StatusCode - http status of the last attempt, zero if there was no response.
+	StatusCode int
+	// ErrorCode - empty on success, the RGW error code, e.g. "NoSuchUser", or
+	// one of ErrorCodeUnknown and ErrorCodeClient.
+	ErrorCode string
+	// Attempts - number of requests sent to the gateway.
+	Attempts int
+	// Duration - wall time of the whole operation.
+	Duration time.Duration
+	// Err - the error returned to the caller, if any.
+	Err error
+}
+
+func newCallObservation(ci *callInfo, method string, d time.Duration, err error) CallObservation {
+	obs := CallObservation{
+		Operation:  ci.op,
+		Method:     method,
+		StatusCode: ci.status,
+		Attempts:   ci.attempts,
+		Duration:   d,
+		Err:        err,
+	}
+	if err == nil {
+		return obs
+	}
+	var rerr *RGWError
+	switch {
+	case !errors.As(err, &rerr):
+		obs.ErrorCode = ErrorCodeClient
+	case rerr.Code != "":
+		obs.ErrorCode = rerr.Code
+	default:
+		obs.ErrorCode = ErrorCodeUnknown
+	}
+	if rerr != nil && obs.StatusCode == 0 {
+		obs.StatusCode = rerr.StatusCode
+	}
+	return obs
+}
@@ -0,0 +1,77 @@
+package radosgwadmin
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// OpMask - the kinds of operations a user may perform, e.g. OpRead alone for a
+// read-only user.  It is formatted like the gateway does, "read, write, delete".
+type OpMask uint8
+
+// OpMask bits, with the gateway's values.
+const (
+	OpRead OpMask = 1 << iota
+	OpWrite
+	OpDelete
+
+	// OpAll - everything, the default for new users.
+	OpAll = OpRead | OpWrite | OpDelete
+)
+
+var opNames = []struct {
+	op   OpMask
+	name string
+}{{OpRead, "read"}, {OpWrite, "write"}, {OpDelete, "delete"}}
+
+// ParseOpMask - parse a comma separated list of "read", "write" and "delete", or
+// "*" for all of them.
+func ParseOpMask(s string) (OpMask, error) {
+	var m OpMask
+fields:
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if f == "*" {
+			m |= OpAll
+			continue
+		}
+		for _, o := range opNames {
+			if f == o.name {
+				m |= o.op
+				continue fields
+			}
+		}
+		return 0, fmt.Errorf("radosgwadmin: bad op mask %q: unknown op %q", s, f)
+	}
+	return m, nil
+}
+
+// String - implements fmt.Stringer
+func (m OpMask) String() string {
+	var names []string
+	for _, o := range opNames {
+		if m&o.op != 0 {
+			names = append(names, o.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// MarshalText - implements TextMarshaler
+func (m OpMask) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText - implements TextUnmarshaler
+func (m *OpMask) UnmarshalText(text []byte) error {
+	var err error
+	*m, err = ParseOpMask(string(text))
+	return err
+}
+
+// EncodeValues - implements query.Encoder.  Note that the gateway ignores an
+// empty op-mask, a user can't be left with no ops at all.
+func (m OpMask) EncodeValues(key string, v *url.Values) error {
+	v.Set(key, m.String())
+	return nil
+}
@@ -0,0 +1,46 @@
+package radosgwadmin
+
+import (
+	"testing"
+
+	"github.com/google/go-querystring/query"
+	"github.com/stretchr/testify/suite"
+)
+
+type OpMaskSuite struct {
+	suite.Suite
+}
+
+func (ops *OpMaskSuite) TestParse() {
+	for s, want := range map[string]OpMask{
+		"read, write, delete": OpAll,
+		"*":                   OpAll,
+		"read":                OpRead,
+		"delete,read":         OpRead | OpDelete,
+		"":                    0,
+	} {
+		m, err := ParseOpMask(s)
+		ops.NoError(err, s)
+		ops.Equal(want, m, s)
+	}
+	_, err := ParseOpMask("read, execute")
+	ops.Error(err)
+
+	ops.Equal("read, write, delete", OpAll.String())
+	ops.Equal("read, delete", (OpDelete | OpRead).String())
+	ops.Equal("", OpMask(0).String())
+}
+
+func (ops *OpMaskSuite) TestQuery() {
+	q, err := query.Values(&UserModifyRequest{UID: UserID{ID: "alice"}, OpMask: OpRead, PlacementTags: []string{"ssd", "fast"}, System: TrueRef})
+	ops.Require().NoError(err)
+	ops.Equal("op-mask=read&placement-tags=ssd%2Cfast&system=true&uid=alice", q.Encode())
+
+	q, err = query.Values(&UserModifyRequest{UID: UserID{ID: "alice"}})
+	ops.Require().NoError(err)
+	ops.Equal("uid=alice", q.Encode())
+}
+
+func TestOpMask(t *testing.T) {
+	suite.Run(t, new(OpMaskSuite))
+}
@@ -0,0 +1,393 @@
+package radosgwadmin
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProvisionConcurrency - default for ProvisionOptions.Concurrency.
+const DefaultProvisionConcurrency = 4
+
+// ProvisionRow - one user in a provisioning manifest.  The CSV columns and YAML
+// keys are the yaml names of the fields, only uid and display_name are required.
+//
+// Caps are in the gateway's form, e.g. "buckets=read;usage=*", and subusers are
+// name=access pairs, e.g. "app=readwrite;backup=read".  Setting either limit of
+// a quota enables it, with the other limit left at -1, i.e. unlimited.  Keys is
+// the number of s3 keys to generate for the user, one if not set.
+type ProvisionRow struct {
+	UID                   UserID `yaml:"uid"`
+	DisplayName           string `yaml:"display_name"`
+	Email                 string `yaml:"email"`
+	MaxBuckets            *int   `yaml:"max_buckets"`
+	Suspended             bool   `yaml:"suspended"`
+	Caps                  string `yaml:"caps"`
+	SubUsers              string `yaml:"subusers"`
+	UserQuotaMaxSizeKb    *int64 `yaml:"user_quota_max_size_kb"`
+	UserQuotaMaxObjects   *int64 `yaml:"user_quota_max_objects"`
+	BucketQuotaMaxSizeKb  *int64 `yaml:"bucket_quota_max_size_kb"`
+	BucketQuotaMaxObjects *int64 `yaml:"bucket_quota_max_objects"`
+	Keys                  *int   `yaml:"keys"`
+}
+
+// ReadManifestYAML - read a provisioning manifest, a YAML list of ProvisionRow.
+func ReadManifestYAML(r io.Reader) ([]ProvisionRow, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var rows []ProvisionRow
+	if err := dec.Decode(&rows); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("radosgwadmin: manifest: %w", err)
+	}
+	for i := range rows {
+		if err := rows[i].check(); err != nil {
+			return nil, fmt.Errorf("radosgwadmin: manifest row %d: %w", i+1, err)
+		}
+	}
+	return rows, nil
+}
+
+// ReadManifestCSV - read a provisioning manifest from CSV with a header line
+// naming the columns, see ProvisionRow.  Empty cells are left unset, and lines
+// starting with # are ignored.
+func ReadManifestCSV(r io.Reader) ([]ProvisionRow, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("radosgwadmin: manifest header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+		if err := (&ProvisionRow{}).set(header[i], ""); err != nil {
+			return nil, fmt.Errorf("radosgwadmin: manifest header: %w", err)
+		}
+	}
+	var rows []ProvisionRow
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("radosgwadmin: manifest: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		row := ProvisionRow{}
+		for i, v := range rec {
+			if err := row.set(header[i], strings.TrimSpace(v)); err != nil {
+				return nil, fmt.Errorf("radosgwadmin: manifest line %d: %w", line, err)
+			}
+		}
+		if err := row.check(); err != nil {
+			return nil, fmt.Errorf("radosgwadmin: manifest line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// set - set the field for a CSV column.  Empty values leave the field unset, but
+// the column is still checked.
+func (pr *ProvisionRow) set(column, v string) error {
+	ints := map[string]**int{"max_buckets": &pr.MaxBuckets, "keys": &pr.Keys}
+	int64s := map[string]**int64{
+		"user_quota_max_size_kb":   &pr.UserQuotaMaxSizeKb,
+		"user_quota_max_objects":   &pr.UserQuotaMaxObjects,
+		"bucket_quota_max_size_kb": &pr.BucketQuotaMaxSizeKb,
+		"bucket_quota_max_objects": &pr.BucketQuotaMaxObjects,
+	}
+	strs := map[string]*string{
+		"display_name": &pr.DisplayName,
+		"email":        &pr.Email,
+		"caps":         &pr.Caps,
+		"subusers":     &pr.SubUsers,
+	}
+	var err error
+	switch {
+	case column == "uid":
+		if v != "" {
+			pr.UID, err = ParseUserID(v)
+		}
+	case column == "suspended":
+		if v != "" {
+			pr.Suspended, err = strconv.ParseBool(v)
+		}
+	case strs[column] != nil:
+		*strs[column] = v
+	case ints[column] != nil:
+		if v != "" {
+			var n int
+			n, err = strconv.Atoi(v)
+			*ints[column] = &n
+		}
+	case int64s[column] != nil:
+		if v != "" {
+			var n int64
+			n, err = strconv.ParseInt(v, 10, 64)
+			*int64s[column] = &n
+		}
+	default:
+		return fmt.Errorf("unknown column %q", column)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", column, err)
+	}
+	return nil
+}
+
+// check - make sure the row makes a valid UserSpec.
+func (pr *ProvisionRow) check() error {
+	_, err := pr.spec()
+	return err
+}
+
+// spec - the UserSpec for the row.
+func (pr *ProvisionRow) spec() (*UserSpec, error) {
+	if pr.UID.ID == "" {
+		return nil, errors.New("uid is required")
+	}
+	if pr.DisplayName == "" {
+		return nil, errors.New("display_name is required")
+	}
+	spec := &UserSpec{
+		UID:         pr.UID,
+		DisplayName: pr.DisplayName,
+		Email:       pr.Email,
+		MaxBuckets:  pr.MaxBuckets,
+		Suspended:   pr.Suspended,
+		UserQuota:   manifestQuota(pr.UserQuotaMaxSizeKb, pr.UserQuotaMaxObjects),
+		BucketQuota: manifestQuota(pr.BucketQuotaMaxSizeKb, pr.BucketQuotaMaxObjects),
+		Keys:        pr.Keys,
+	}
+	if pr.Caps != "" {
+		caps, err := ParseCaps(pr.Caps)
+		if err != nil {
+			return nil, fmt.Errorf("caps: %w", err)
+		}
+		spec.Caps = caps.Caps()
+	}
+	for _, su := range manifestList(pr.SubUsers) {
+		name, access, _ := cutString(su, "=")
+		spec.SubUsers = append(spec.SubUsers, SubUserSpec{Name: name, Access: access})
+	}
+	for _, su := range spec.SubUsers {
+		err := validate.Struct(&SubUserCreateModifyRequest{UID: pr.UID, SubUser: su.Name, Access: su.Access})
+		if err != nil {
+			return nil, fmt.Errorf("subusers: %q: %w", su.Name, err)
+		}
+	}
+	return spec, nil
+}
+
+// manifestList - split a ;-separated manifest cell.
+func manifestList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// manifestQuota - an enabled quota if either limit is set.
+func manifestQuota(sizeKb, objects *int64) *QuotaMeta {
+	if sizeKb == nil && objects == nil {
+		return nil
+	}
+	q := &QuotaMeta{Enabled: true, MaxSizeKb: -1, MaxObjects: -1}
+	if sizeKb != nil {
+		q.MaxSizeKb = *sizeKb
+	}
+	if objects != nil {
+		q.MaxObjects = *objects
+	}
+	return q
+}
+
+// cutString - strings.Cut, which needs go 1.18.
+func cutString(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// ProvisionStatus - the outcome of provisioning a row.
+type ProvisionStatus string
+
+const (
+	// ProvisionCreated - the user was created.
+	ProvisionCreated ProvisionStatus = "created"
+	// ProvisionExists - the user already existed and was left alone.
+	ProvisionExists ProvisionStatus = "exists"
+	// ProvisionFailed - see ProvisionResult.Error.  The user may have been
+	// created, but not fully set up; provisioning again with the report as
+	// ProvisionOptions.Previous finishes the job.
+	ProvisionFailed ProvisionStatus = "failed"
+)
+
+// ProvisionResult - a line of the provisioning report.
+type ProvisionResult struct {
+	Row       int             `json:"row"` // 1 based index in the manifest
+	UID       string          `json:"uid"`
+	Status    ProvisionStatus `json:"status"`
+	Keys      []UserKey       `json:"keys,omitempty"`
+	SwiftKeys []SwiftKey      `json:"swift_keys,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// ReadProvisionReport - read a report written by Provision, to resume from.  A
+// truncated last line, from an interrupted run, is ignored.
+func ReadProvisionReport(r io.Reader) ([]ProvisionResult, error) {
+	var results []ProvisionResult
+	dec := json.NewDecoder(r)
+	for {
+		var res ProvisionResult
+		err := dec.Decode(&res)
+		switch {
+		case err == io.EOF, errors.Is(err, io.ErrUnexpectedEOF):
+			return results, nil
+		case err != nil:
+			return nil, fmt.Errorf("radosgwadmin: provision report: %w", err)
+		}
+		results = append(results, res)
+	}
+}
+
+// ProvisionOptions - options for Provision.
+type ProvisionOptions struct {
+	// Concurrency - the number of users to provision at a time, defaults to
+	// DefaultProvisionConcurrency.
+	Concurrency int
+	// Report - if set, each result is written here as a line of JSON as soon as
+	// the row is done, so an interrupted run still leaves a usable report.  Note
+	// that it includes the secret keys of the users created, see SecretEncoder.
+	Report io.Writer
+	// Previous - the report of an earlier run of the same manifest, see
+	// ReadProvisionReport.  Users it reports created or existing are not
+	// touched again and their results are carried over, so the new report is
+	// complete.  Users it reports failed are finished off, even though they
+	// may exist by now.
+	Previous []ProvisionResult
+}
+
+// ErrProvisionIncomplete - returned by Provision when some rows failed.
+var ErrProvisionIncomplete = errors.New("radosgwadmin: provisioning incomplete")
+
+// Provision - create the users in a manifest, with their caps, quotas, subusers
+// and keys, opts.Concurrency at a time.  Users that already exist are skipped.
+// The results are returned in manifest order.  If any rows failed the error
+// wraps ErrProvisionIncomplete, and the run can be resumed by passing the
+// results as opts.Previous.
+func (aa *AdminAPI) Provision(ctx context.Context, rows []ProvisionRow, opts ProvisionOptions) ([]ProvisionResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultProvisionConcurrency
+	}
+	previous := map[string]ProvisionResult{}
+	for _, res := range opts.Previous {
+		previous[res.UID] = res
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		reportErr error
+		failed    int
+	)
+	var report *SecretEncoder
+	if opts.Report != nil {
+		report = NewSecretEncoder(opts.Report)
+	}
+	results := make([]ProvisionResult, len(rows))
+	done := func(i int, res ProvisionResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[i] = res
+		if res.Status == ProvisionFailed {
+			failed++
+		}
+		if report != nil && reportErr == nil {
+			reportErr = report.Encode(res)
+		}
+	}
+
+	sem := make(chan struct{}, concurrency)
+	for i := range rows {
+		row := &rows[i]
+		prev, resume := previous[row.UID.String()]
+		if resume && prev.Status != ProvisionFailed {
+			prev.Row = i + 1
+			done(i, prev)
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			done(i, ProvisionResult{Row: i + 1, UID: row.UID.String(), Status: ProvisionFailed, Error: ctx.Err().Error()})
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			done(i, aa.provisionRow(ctx, i, row, resume))
+		}(i)
+	}
+	wg.Wait()
+
+	switch {
+	case reportErr != nil:
+		return results, fmt.Errorf("radosgwadmin: provision report: %w", reportErr)
+	case failed > 0:
+		return results, fmt.Errorf("%w: %d of %d rows failed", ErrProvisionIncomplete, failed, len(rows))
+	}
+	return results, nil
+}
+
+// provisionRow - create the user for a row, or with resume, finish setting it up.
+func (aa *AdminAPI) provisionRow(ctx context.Context, i int, row *ProvisionRow, resume bool) ProvisionResult {
+	res := ProvisionResult{Row: i + 1, UID: row.UID.String(), Status: ProvisionFailed}
+	spec, err := row.spec()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	p, err := aa.Reconcile(ctx, spec, ReconcileOptions{DryRun: true})
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if !p.Create && !resume {
+		res.Status = ProvisionExists
+		return res
+	}
+	if err = p.Apply(ctx); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	ui, err := aa.UserInfo(ctx, spec.UID, false)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Status = ProvisionCreated
+	// Leave them nil when empty, as they are when read back from a report.
+	if len(ui.Keys) > 0 {
+		res.Keys = ui.Keys
+	}
+	if len(ui.SwiftKeys) > 0 {
+		res.SwiftKeys = ui.SwiftKeys
+	}
+	return res
+}
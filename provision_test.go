@@ -0,0 +1,133 @@
+package radosgwadmin_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	rgw "github.com/myENA/radosgwadmin"
+	"github.com/stretchr/testify/suite"
+)
+
+const manifestCSV = `uid,display_name,email,caps,subusers,user_quota_max_objects,keys
+# onboarding for acme
+acme$alice,Alice,alice@acme.com,buckets=read;usage=read,app=readwrite,1000,
+acme$bob,Bob,bob@acme.com,,,,2
+acme$carol,Carol,carol@acme.com,,,,0
+`
+
+const manifestYAML = `
+- uid: acme$alice
+  display_name: Alice
+  email: alice@acme.com
+  caps: buckets=read;usage=read
+  subusers: app=readwrite
+  user_quota_max_objects: 1000
+- uid: acme$bob
+  display_name: Bob
+  email: bob@acme.com
+  keys: 2
+- uid: acme$carol
+  display_name: Carol
+  email: carol@acme.com
+  keys: 0
+`
+
+type ProvisionSuite struct {
+	fakeSuite
+}
+
+func (ps *ProvisionSuite) TestManifests() {
+	fromCSV, err := rgw.ReadManifestCSV(strings.NewReader(manifestCSV))
+	ps.Require().NoError(err)
+	fromYAML, err := rgw.ReadManifestYAML(strings.NewReader(manifestYAML))
+	ps.Require().NoError(err)
+	ps.Equal(fromYAML, fromCSV)
+	ps.Require().Len(fromCSV, 3)
+	ps.Equal(rgw.UserID{Tenant: "acme", ID: "alice"}, fromCSV[0].UID)
+	ps.Nil(fromCSV[0].Keys)
+	ps.Equal(0, *fromCSV[2].Keys)
+
+	for _, bad := range []string{
+		"uid,display_name,colour\nx,X,red\n",
+		"uid,display_name\n,X\n",
+		"uid,display_name,keys\nx,X,many\n",
+		"uid,display_name,caps\nx,X,buckets=sometimes\n",
+		"uid,display_name,subusers\nx,X,app=all\n",
+	} {
+		_, err = rgw.ReadManifestCSV(strings.NewReader(bad))
+		ps.Error(err, bad)
+	}
+	_, err = rgw.ReadManifestYAML(strings.NewReader("- uid: x\n  display_name: X\n  colour: red\n"))
+	ps.Error(err)
+}
+
+func (ps *ProvisionSuite) TestProvision() {
+	rows, err := rgw.ReadManifestCSV(strings.NewReader(manifestCSV))
+	ps.Require().NoError(err)
+	_, err = ps.aa.UserCreate(ps.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{Tenant: "acme", ID: "bob"}, DisplayName: "Robert"})
+	ps.Require().NoError(err)
+
+	report := &bytes.Buffer{}
+	results, err := ps.aa.Provision(ps.ctx, rows, rgw.ProvisionOptions{Concurrency: 2, Report: report})
+	ps.Require().NoError(err)
+	ps.Require().Len(results, 3)
+	ps.Equal(rgw.ProvisionCreated, results[0].Status)
+	ps.Len(results[0].Keys, 1)
+	ps.Equal(rgw.ProvisionExists, results[1].Status)
+	ps.Empty(results[1].Keys)
+	ps.Equal(rgw.ProvisionCreated, results[2].Status)
+	ps.Empty(results[2].Keys)
+	for i, res := range results {
+		ps.Equal(i+1, res.Row)
+	}
+
+	ui, err := ps.aa.UserInfo(ps.ctx, rows[0].UID, false)
+	ps.Require().NoError(err)
+	ps.Equal([]rgw.UserCap{{Type: "buckets", Permission: "read"}, {Type: "usage", Permission: "read"}}, ui.Caps)
+	ps.Equal([]rgw.SubUser{{ID: "acme$alice:app", Permissions: "read-write"}}, ui.SubUsers)
+	q, err := ps.aa.QuotaUser(ps.ctx, rows[0].UID)
+	ps.Require().NoError(err)
+	// An unlimited size is reported as 0.
+	ps.Equal(rgw.QuotaMeta{Enabled: true, MaxSizeKb: 0, MaxObjects: 1000}, *q)
+	ui, err = ps.aa.UserInfo(ps.ctx, rows[1].UID, false)
+	ps.Require().NoError(err)
+	ps.Equal("Robert", ui.DisplayName, "existing users are left alone")
+
+	reported, err := rgw.ReadProvisionReport(report)
+	ps.Require().NoError(err)
+	ps.ElementsMatch(results, reported)
+}
+
+func (ps *ProvisionSuite) TestResume() {
+	rows, err := rgw.ReadManifestYAML(strings.NewReader(manifestYAML))
+	ps.Require().NoError(err)
+	_, err = ps.aa.UserCreate(ps.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: "squatter"}, DisplayName: "S", Email: "carol@acme.com"})
+	ps.Require().NoError(err)
+
+	report := &bytes.Buffer{}
+	first, err := ps.aa.Provision(ps.ctx, rows, rgw.ProvisionOptions{Report: report})
+	ps.True(errors.Is(err, rgw.ErrProvisionIncomplete), "got %v", err)
+	ps.Equal(rgw.ProvisionFailed, first[2].Status)
+	ps.Contains(first[2].Error, "EmailExists")
+
+	// An interrupted run may leave half a line behind.
+	report.WriteString(`{"row":4,"uid":"acme$da`)
+	previous, err := rgw.ReadProvisionReport(report)
+	ps.Require().NoError(err)
+	ps.Len(previous, 3)
+
+	ps.Require().NoError(ps.aa.UserRm(ps.ctx, rgw.UserID{ID: "squatter"}, false))
+	second, err := ps.aa.Provision(ps.ctx, rows, rgw.ProvisionOptions{Previous: previous})
+	ps.Require().NoError(err)
+	ps.Equal(first[:2], second[:2], "finished rows are carried over")
+	ps.Equal(rgw.ProvisionCreated, second[2].Status)
+	ui, err := ps.aa.UserInfo(ps.ctx, rows[2].UID, false)
+	ps.Require().NoError(err)
+	ps.Equal("carol@acme.com", ui.Email)
+}
+
+func TestProvision(t *testing.T) {
+	suite.Run(t, new(ProvisionSuite))
+}
@@ -11,7 +11,7 @@ type quotaGetRequest struct {
 
 // QuotaSetRequest - passed to a QuotaSet() call
 type QuotaSetRequest struct {
-	UID            string `url:"uid" validate:"required"`
+	UID            UserID `url:"uid" validate:"required"`
 	QuotaType      string `url:"quota-type" validate:"eq=user|eq=bucket"`
 	MaximumObjects int    `url:"max-objects,omitempty"`
 	MaximumSizeKb  int    `url:"max-size-kb,omitempty"`
@@ -32,30 +32,30 @@ type Quotas struct {
 }
 
 // Quotas - get user and bucket quota info by uid
-func (aa *AdminAPI) Quotas(ctx context.Context, uid string) (*Quotas, error) {
+func (aa *AdminAPI) Quotas(ctx context.Context, uid UserID) (*Quotas, error) {
 	resp := &Quotas{}
-	req := &quotaGetRequest{UID: uid}
-	err := aa.Get(ctx, "/user?quota", req, &resp)
+	req := &quotaGetRequest{UID: uid.String()}
+	err := aa.get(ctx, "Quotas", "/user?quota", req, &resp)
 	return resp, err
 }
 
 // QuotaBucket - get bucket quota info by uid
-func (aa *AdminAPI) QuotaBucket(ctx context.Context, uid string) (*QuotaMeta, error) {
+func (aa *AdminAPI) QuotaBucket(ctx context.Context, uid UserID) (*QuotaMeta, error) {
 	resp := &QuotaMeta{}
-	req := &quotaGetRequest{UID: uid, QuotaType: "bucket"}
-	err := aa.Get(ctx, "/user?quota", req, &resp)
+	req := &quotaGetRequest{UID: uid.String(), QuotaType: "bucket"}
+	err := aa.get(ctx, "QuotaBucket", "/user?quota", req, &resp)
 	return resp, err
 }
 
 // QuotaUser - get user quota info by uid.
-func (aa *AdminAPI) QuotaUser(ctx context.Context, uid string) (*QuotaMeta, error) {
+func (aa *AdminAPI) QuotaUser(ctx context.Context, uid UserID) (*QuotaMeta, error) {
 	resp := &QuotaMeta{}
-	req := &quotaGetRequest{UID: uid, QuotaType: "user"}
-	err := aa.Get(ctx, "/user?quota", req, &resp)
+	req := &quotaGetRequest{UID: uid.String(), QuotaType: "user"}
+	err := aa.get(ctx, "QuotaUser", "/user?quota", req, &resp)
 	return resp, err
 }
 
 // QuotaSet - Set a quota
 func (aa *AdminAPI) QuotaSet(ctx context.Context, qsr *QuotaSetRequest) error {
-	return aa.Put(ctx, "/user?quota", qsr, nil, nil)
+	return aa.put(ctx, "QuotaSet", "/user?quota", qsr, nil)
 }
@@ -0,0 +1,293 @@
+package radosgwadmintest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxClockSkew - requests dated further than this from the server's clock are
+// refused with RequestTimeTooSkewed, like the gateway does.
+const MaxClockSkew = 15 * time.Minute
+
+// v2SubResources - query parameters that are part of the V2 canonical resource.
+var v2SubResources = map[string]bool{
+	"acl": true, "cors": true, "delete": true, "lifecycle": true, "location": true,
+	"logging": true, "notification": true, "partNumber": true, "policy": true,
+	"requestPayment": true, "tagging": true, "torrent": true, "uploadId": true,
+	"uploads": true, "versionId": true, "versioning": true, "versions": true,
+	"website": true,
+}
+
+// authenticate - check the signature of r, and return the uid of the user
+// whose key signed it.
+func (s *Server) authenticate(r *http.Request, body []byte) (string, *apiError) {
+	auth := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(auth, "AWS4-HMAC-SHA256 "):
+		return s.authenticateV4(r, body, strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "))
+	case strings.HasPrefix(auth, "AWS "):
+		return s.authenticateV2(r, strings.TrimPrefix(auth, "AWS "))
+	}
+	return "", errAccessDenied
+}
+
+// secretFor - the secret of an s3 access key, and the uid it belongs to.
+func (s *Server) secretFor(accessKey string) (string, string, *apiError) {
+	uid, ok := s.keys[accessKey]
+	if !ok {
+		return "", "", errInvalidAccessKeyID
+	}
+	u := s.users[uid]
+	for _, k := range u.Keys {
+		if k.AccessKey != accessKey {
+			continue
+		}
+		if u.Suspended != 0 {
+			return "", "", errUserSuspended
+		}
+		if !k.Active {
+			return "", "", errInvalidAccessKeyID
+		}
+		return k.SecretKey, uid, nil
+	}
+	return "", "", errInvalidAccessKeyID
+}
+
+func (s *Server) checkSkew(t time.Time) *apiError {
+	d := s.now().Sub(t)
+	if d > MaxClockSkew || d < -MaxClockSkew {
+		return errRequestTimeTooSkewed
+	}
+	return nil
+}
+
+// parseHTTPDate - clients send RFC 1123 dates with either a zone name or a
+// numeric offset.
+func parseHTTPDate(v string) (time.Time, bool) {
+	if t, err := http.ParseTime(v); err == nil {
+		return t, true
+	}
+	t, err := time.Parse(time.RFC1123Z, v)
+	return t, err == nil
+}
+
+func (s *Server) authenticateV2(r *http.Request, cred string) (string, *apiError) {
+	i := strings.LastIndex(cred, ":")
+	if i < 0 {
+		return "", errAccessDenied
+	}
+	accessKey, signature := cred[:i], cred[i+1:]
+	secret, uid, aerr := s.secretFor(accessKey)
+	if aerr != nil {
+		return "", aerr
+	}
+
+	// X-Amz-Date takes the place of Date, and is signed with the other x-amz
+	// headers instead.
+	date := r.Header.Get("Date")
+	t, ok := parseHTTPDate(date)
+	if xdate := r.Header.Get("X-Amz-Date"); xdate != "" {
+		date = ""
+		t, ok = parseHTTPDate(xdate)
+	}
+	if !ok {
+		return "", errAccessDenied
+	}
+	if aerr := s.checkSkew(t); aerr != nil {
+		return "", aerr
+	}
+
+	// Only the header is signed, an absent one as empty, whatever the body.
+	md5sum := r.Header.Get("Content-Md5")
+
+	var amz []string
+	for k := range r.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-amz-") {
+			amz = append(amz, lk+":"+strings.Join(r.Header[k], ","))
+		}
+	}
+	sort.Strings(amz)
+
+	resource := r.URL.EscapedPath()
+	var subs []string
+	for k := range r.URL.Query() {
+		if v2SubResources[k] {
+			subs = append(subs, k)
+		}
+	}
+	sort.Strings(subs)
+	for i, sub := range subs {
+		if i == 0 {
+			resource += "?" + sub
+		} else {
+			resource += "&" + sub
+		}
+	}
+
+	var sts bytes.Buffer
+	sts.WriteString(r.Method + "\n" + md5sum + "\n" + r.Header.Get("Content-Type") + "\n" + date + "\n")
+	for _, h := range amz {
+		sts.WriteString(h + "\n")
+	}
+	sts.WriteString(resource)
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(sts.Bytes())
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return "", errSignatureDoesNotMatch
+	}
+	return uid, nil
+}
+
+func (s *Server) authenticateV4(r *http.Request, body []byte, params string) (string, *apiError) {
+	var credential, signedHeaders, signature string
+	for _, p := range strings.Split(params, ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) != 2 {
+			return "", errAccessDenied
+		}
+		switch kv[0] {
+		case "Credential":
+			credential = kv[1]
+		case "SignedHeaders":
+			signedHeaders = kv[1]
+		case "Signature":
+			signature = kv[1]
+		}
+	}
+	// access key/date/region/service/aws4_request
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" || signedHeaders == "" || signature == "" {
+		return "", errAccessDenied
+	}
+	if s.Region != "" && scope[2] != s.Region {
+		return "", errSignatureDoesNotMatch
+	}
+	secret, uid, aerr := s.secretFor(scope[0])
+	if aerr != nil {
+		return "", aerr
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	t, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, scope[1]) {
+		return "", errAccessDenied
+	}
+	if aerr := s.checkSkew(t); aerr != nil {
+		return "", aerr
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return "", errAccessDenied
+	}
+	if payloadHash != "UNSIGNED-PAYLOAD" && payloadHash != hexSHA256(body) {
+		return "", errSignatureDoesNotMatch
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, h := range strings.Split(signedHeaders, ";") {
+		v := r.Header.Get(h)
+		if h == "host" {
+			v = r.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.Join(strings.Fields(v), " ") + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL),
+		canonicalQuery(r.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		strings.Join(scope[1:], "/"),
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := []byte("AWS4" + secret)
+	for _, part := range scope[1:] {
+		key = hmacSHA256(key, part)
+	}
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return "", errSignatureDoesNotMatch
+	}
+	return uid, nil
+}
+
+func canonicalURI(u *url.URL) string {
+	segs := strings.Split(u.EscapedPath(), "/")
+	for i, seg := range segs {
+		if unescaped, err := url.PathUnescape(seg); err == nil {
+			seg = unescaped
+		}
+		segs[i] = awsEscape(seg)
+	}
+	if p := strings.Join(segs, "/"); p != "" {
+		return p
+	}
+	return "/"
+}
+
+// canonicalQuery - sorted by encoded key, then by encoded value.
+func canonicalQuery(u *url.URL) string {
+	type pair struct{ k, v string }
+	var pairs []pair
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			pairs = append(pairs, pair{awsEscape(k), awsEscape(v)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].k != pairs[j].k {
+			return pairs[i].k < pairs[j].k
+		}
+		return pairs[i].v < pairs[j].v
+	})
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p.k + "=" + p.v
+	}
+	return strings.Join(parts, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readBody - read and replace the request body, for signature checks.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, err
+}
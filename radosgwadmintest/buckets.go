@@ -0,0 +1,348 @@
+package radosgwadmintest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Pool names reported for buckets.
+const (
+	DataPool      = "default.rgw.buckets.data"
+	IndexPool     = "default.rgw.buckets.index"
+	DataExtraPool = "default.rgw.buckets.non-ec"
+)
+
+const (
+	zoneID        = "8d0a3cfb-5c9a-4a3f-9e23-1e1dbb3b2fa1"
+	zoneGroupID   = "2cfae4d3-2b56-4c59-9a31-0ad5cbc9f1b4"
+	bucketTimeFmt = "2006-01-02 15:04:05.000000"
+	metaTimeFmt   = "2006-01-02 15:04:05.000000Z"
+)
+
+type bucket struct {
+	name    string
+	id      string
+	owner   string
+	created time.Time
+	objects map[string]int64 // object name to size
+	quota   quota
+}
+
+// usage - total size and number of objects.
+func (b *bucket) usage() (int64, int64) {
+	var size int64
+	for _, sz := range b.objects {
+		size += sz
+	}
+	return size, int64(len(b.objects))
+}
+
+// roundUp - size rounded up to the 4k the gateway accounts in.
+func roundUp(size int64) int64 {
+	return (size + 4095) &^ 4095
+}
+
+// AddBucket - create a bucket owned by uid, which must exist.  Returns the
+// bucket id.
+func (s *Server) AddBucket(uid, name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[uid]; !ok {
+		return "", fmt.Errorf("no such user %q", uid)
+	}
+	if _, ok := s.buckets[name]; ok {
+		return "", fmt.Errorf("bucket %q already exists", name)
+	}
+	s.seq++
+	b := &bucket{
+		name:    name,
+		id:      fmt.Sprintf("%s.%d.%d", zoneID, 4133, s.seq),
+		owner:   uid,
+		created: s.now().UTC(),
+		objects: make(map[string]int64),
+		quota:   defaultQuota(),
+	}
+	s.buckets[name] = b
+	return b.id, nil
+}
+
+// AddObject - put an object of the given size in a bucket.
+func (s *Server) AddObject(bucketName, object string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		return fmt.Errorf("no such bucket %q", bucketName)
+	}
+	b.objects[object] = size
+	return nil
+}
+
+type bucketUsageEntry struct {
+	Size           int64 `json:"size"`
+	SizeActual     int64 `json:"size_actual"`
+	SizeUtilized   int64 `json:"size_utilized"`
+	SizeKb         int64 `json:"size_kb"`
+	SizeKbActual   int64 `json:"size_kb_actual"`
+	SizeKbUtilized int64 `json:"size_kb_utilized"`
+	NumObjects     int64 `json:"num_objects"`
+}
+
+type bucketStats struct {
+	Bucket      string                      `json:"bucket"`
+	Pool        string                      `json:"pool"`
+	IndexPool   string                      `json:"index_pool"`
+	ID          string                      `json:"id"`
+	Marker      string                      `json:"marker"`
+	Owner       string                      `json:"owner"`
+	Ver         string                      `json:"ver"`
+	MasterVer   string                      `json:"master_ver"`
+	Mtime       string                      `json:"mtime"`
+	MaxMarker   string                      `json:"max_marker"`
+	Usage       map[string]bucketUsageEntry `json:"usage"`
+	BucketQuota quota                       `json:"bucket_quota"`
+}
+
+func (b *bucket) usageMap() map[string]bucketUsageEntry {
+	usage := map[string]bucketUsageEntry{}
+	if size, n := b.usage(); n > 0 {
+		usage["rgw.main"] = bucketUsageEntry{
+			Size:           size,
+			SizeActual:     roundUp(size),
+			SizeUtilized:   size,
+			SizeKb:         (size + 1023) / 1024,
+			SizeKbActual:   roundUp(size) / 1024,
+			SizeKbUtilized: (size + 1023) / 1024,
+			NumObjects:     n,
+		}
+	}
+	return usage
+}
+
+func (b *bucket) stats() *bucketStats {
+	return &bucketStats{
+		Bucket:      b.name,
+		Pool:        DataPool,
+		IndexPool:   IndexPool,
+		ID:          b.id,
+		Marker:      b.id,
+		Owner:       b.owner,
+		Ver:         fmt.Sprintf("0#%d", len(b.objects)+1),
+		MasterVer:   "0#0",
+		Mtime:       b.created.Format(bucketTimeFmt),
+		MaxMarker:   "0#",
+		Usage:       b.usageMap(),
+		BucketQuota: b.quota,
+	}
+}
+
+func (s *Server) bucketHandler(q url.Values) handler {
+	switch {
+	case hasParam(q, "index"):
+		return methods(s.bucketIndex, nil, nil, nil)
+	case hasParam(q, "policy"):
+		return methods(s.bucketPolicy, nil, nil, nil)
+	case hasParam(q, "object"):
+		// ?object names the resource, and object=name the object.
+		q["object"] = nonEmpty(q["object"])
+		return methods(nil, nil, nil, s.bucketObjectRm)
+	}
+	return methods(s.bucketInfo, s.bucketLink, s.bucketUnlink, s.bucketRm)
+}
+
+// lookupBucket - the bucket named by the bucket parameter.
+func (s *Server) lookupBucket(q url.Values) (*bucket, *apiError) {
+	name := q.Get("bucket")
+	if name == "" {
+		return nil, errInvalidArgument
+	}
+	b, ok := s.buckets[name]
+	if !ok {
+		return nil, errNoSuchBucket
+	}
+	return b, nil
+}
+
+// sortedBuckets - buckets owned by uid, or all of them, by name.
+func (s *Server) sortedBuckets(uid string) []*bucket {
+	var out []*bucket
+	for _, b := range s.buckets {
+		if uid == "" || b.owner == uid {
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+// listAllMyBuckets - the S3 ListBuckets response for uid, which is only served
+// so that credentials can be checked.
+func (s *Server) listAllMyBuckets(uid string) interface{} {
+	type owner struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"display_name"`
+	}
+	type entry struct {
+		Name string `json:"name"`
+	}
+	buckets := []entry{}
+	for _, b := range s.sortedBuckets(uid) {
+		buckets = append(buckets, entry{Name: b.name})
+	}
+	return map[string]interface{}{
+		"owner":   owner{ID: uid, DisplayName: s.users[uid].DisplayName},
+		"buckets": buckets,
+	}
+}
+
+// bucketInfo - the stats of one bucket, or a list of bucket names or stats.
+func (s *Server) bucketInfo(r *http.Request, q url.Values) (interface{}, *apiError) {
+	if q.Get("bucket") != "" {
+		b, aerr := s.lookupBucket(q)
+		if aerr != nil {
+			return nil, aerr
+		}
+		return b.stats(), nil
+	}
+	uid := q.Get("uid")
+	if _, ok := s.users[uid]; uid != "" && !ok {
+		return nil, errNoSuchUser
+	}
+	buckets := s.sortedBuckets(uid)
+	if boolParam(q, "stats", false) {
+		out := []*bucketStats{}
+		for _, b := range buckets {
+			out = append(out, b.stats())
+		}
+		return out, nil
+	}
+	out := []string{}
+	for _, b := range buckets {
+		out = append(out, b.name)
+	}
+	return out, nil
+}
+
+// bucketIndex - the gateway answers with the list of objects missing from the
+// index, followed by the index headers, as two json documents.
+func (s *Server) bucketIndex(r *http.Request, q url.Values) (interface{}, *apiError) {
+	b, aerr := s.lookupBucket(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	headers := map[string]interface{}{
+		"existing_header":   map[string]interface{}{"usage": b.usageMap()},
+		"calculated_header": map[string]interface{}{"usage": b.usageMap()},
+	}
+	objs, _ := json.Marshal([]string{})
+	hdrs, _ := json.Marshal(headers)
+	return rawJSON(append(objs, hdrs...)), nil
+}
+
+type policyGrant struct {
+	ID    string `json:"id"`
+	Grant struct {
+		Type struct {
+			Type int `json:"type"`
+		} `json:"type"`
+		ID         string `json:"id"`
+		Email      string `json:"email"`
+		Permission struct {
+			Flags int `json:"flags"`
+		} `json:"permission"`
+		Name  string `json:"name"`
+		Group int    `json:"group"`
+	} `json:"grant"`
+}
+
+// bucketPolicy - the owner has full control, nobody else has access.
+func (s *Server) bucketPolicy(r *http.Request, q url.Values) (interface{}, *apiError) {
+	b, aerr := s.lookupBucket(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if obj := q.Get("object"); obj != "" {
+		if _, ok := b.objects[obj]; !ok {
+			return nil, errNoSuchKey
+		}
+	}
+	owner := s.users[b.owner]
+	name := ""
+	if owner != nil {
+		name = owner.DisplayName
+	}
+	g := policyGrant{ID: b.owner}
+	g.Grant.ID = b.owner
+	g.Grant.Name = name
+	g.Grant.Permission.Flags = 15
+	return map[string]interface{}{
+		"acl": map[string]interface{}{
+			"acl_user_map":  []map[string]interface{}{{"user": b.owner, "acl": 15}},
+			"acl_group_map": []interface{}{},
+			"grant_map":     []policyGrant{g},
+		},
+		"owner": map[string]string{"id": b.owner, "display_name": name},
+	}, nil
+}
+
+func (s *Server) bucketObjectRm(r *http.Request, q url.Values) (interface{}, *apiError) {
+	b, aerr := s.lookupBucket(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	obj := q.Get("object")
+	if _, ok := b.objects[obj]; !ok {
+		return nil, errNoSuchKey
+	}
+	delete(b.objects, obj)
+	return nil, nil
+}
+
+func (s *Server) bucketRm(r *http.Request, q url.Values) (interface{}, *apiError) {
+	b, aerr := s.lookupBucket(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if len(b.objects) > 0 && !boolParam(q, "purge-objects", false) {
+		return nil, errBucketNotEmpty
+	}
+	delete(s.buckets, b.name)
+	return nil, nil
+}
+
+// bucketLink - link to a new owner.  bucket-id, if given, must match.
+func (s *Server) bucketLink(r *http.Request, q url.Values) (interface{}, *apiError) {
+	b, aerr := s.lookupBucket(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if id := q.Get("bucket-id"); id != "" && id != b.id {
+		return nil, errInvalidArgument
+	}
+	b.owner = u.UserID
+	return nil, nil
+}
+
+func (s *Server) bucketUnlink(r *http.Request, q url.Values) (interface{}, *apiError) {
+	b, aerr := s.lookupBucket(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if b.owner != u.UserID {
+		return nil, errInvalidArgument
+	}
+	b.owner = ""
+	return nil, nil
+}
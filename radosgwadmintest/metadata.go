@@ -0,0 +1,227 @@
+package radosgwadmintest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type metaVer struct {
+	Tag string `json:"tag"`
+	Ver int    `json:"ver"`
+}
+
+type metaResponse struct {
+	Key   string      `json:"key"`
+	Ver   metaVer     `json:"ver"`
+	Mtime string      `json:"mtime"`
+	Data  interface{} `json:"data"`
+}
+
+type metaBucket struct {
+	Name          string `json:"name"`
+	Marker        string `json:"marker"`
+	BucketID      string `json:"bucket_id"`
+	Tenant        string `json:"tenant"`
+	Pool          string `json:"pool"`
+	DataExtraPool string `json:"data_extra_pool"`
+	IndexPool     string `json:"index_pool"`
+}
+
+func (b *bucket) metaBucket() metaBucket {
+	return metaBucket{
+		Name:          b.name,
+		Marker:        b.id,
+		BucketID:      b.id,
+		Pool:          DataPool,
+		DataExtraPool: DataExtraPool,
+		IndexPool:     IndexPool,
+	}
+}
+
+// metadataSections - what GET /metadata lists.
+var metadataSections = []string{"bucket", "bucket.instance", "user"}
+
+func (s *Server) metadataHandler(section string) handler {
+	var put handler
+	if section == "user" {
+		put = s.metaUserPut
+	}
+	return methods(func(r *http.Request, q url.Values) (interface{}, *apiError) {
+		key := q.Get("key")
+		switch section {
+		case "":
+			return metadataSections, nil
+		case "user":
+			if key == "" {
+				return s.metaList(q, func(add func(string)) {
+					for id := range s.users {
+						add(id)
+					}
+				})
+			}
+			return s.metaUser(key)
+		case "bucket":
+			if key == "" {
+				return s.metaList(q, func(add func(string)) {
+					for name := range s.buckets {
+						add(name)
+					}
+				})
+			}
+			return s.metaBucket(key)
+		case "bucket.instance":
+			if key == "" {
+				return s.metaList(q, func(add func(string)) {
+					for name, b := range s.buckets {
+						add(name + ":" + b.id)
+					}
+				})
+			}
+			return s.metaBucketInstance(key)
+		}
+		return nil, errNoSuchKey
+	}, put, nil, nil)
+}
+
+type metaListPage struct {
+	Keys      []string `json:"keys"`
+	Truncated bool     `json:"truncated"`
+	Count     int      `json:"count"`
+	Marker    string   `json:"marker,omitempty"`
+}
+
+// metaList - the sorted keys, or with max-entries, a page of them after marker.
+func (s *Server) metaList(q url.Values, each func(add func(string))) (interface{}, *apiError) {
+	keys := []string{}
+	each(func(k string) { keys = append(keys, k) })
+	sort.Strings(keys)
+	max, set, aerr := intParam(q, "max-entries")
+	if !set {
+		return keys, aerr
+	}
+	if max <= 0 {
+		return nil, errInvalidArgument
+	}
+	marker := q.Get("marker")
+	i := sort.SearchStrings(keys, marker)
+	if i < len(keys) && keys[i] == marker {
+		i++
+	}
+	page := &metaListPage{Keys: keys[i:]}
+	if len(page.Keys) > int(max) {
+		page.Keys = page.Keys[:max]
+		page.Truncated = true
+		page.Marker = page.Keys[max-1]
+	}
+	page.Count = len(page.Keys)
+	return page, nil
+}
+
+func (s *Server) metaUser(key string) (interface{}, *apiError) {
+	u, ok := s.users[key]
+	if !ok {
+		return nil, errNoSuchKey
+	}
+	return &metaResponse{
+		Key:   "user:" + key,
+		Ver:   metaVer{Tag: "_fake_" + key, Ver: 1},
+		Mtime: s.now().UTC().Format(metaTimeFmt),
+		Data:  s.info(u, false),
+	}, nil
+}
+
+// metaUserPut - replace the user named by key, or add it, with the data in the
+// body.  Its keys are taken as they are, secrets included.
+func (s *Server) metaUserPut(r *http.Request, q url.Values) (interface{}, *apiError) {
+	key := q.Get("key")
+	in := struct {
+		Data *user `json:"data"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Data == nil || in.Data.UserID != key {
+		return nil, errInvalidArgument
+	}
+	u := in.Data
+	u.caps = make(map[string]int)
+	for _, c := range u.Caps {
+		caps, aerr := parseCaps(c.Type + "=" + c.Perm)
+		if aerr != nil {
+			return nil, aerr
+		}
+		u.caps[c.Type] |= caps[c.Type]
+	}
+	u.Caps, u.Stats = nil, nil
+	for _, k := range u.Keys {
+		if owner, ok := s.keys[k.AccessKey]; ok && owner != key {
+			return nil, errKeyExists
+		}
+	}
+	if old, ok := s.users[key]; ok {
+		for _, k := range old.Keys {
+			delete(s.keys, k.AccessKey)
+		}
+	}
+	for _, k := range u.Keys {
+		s.keys[k.AccessKey] = key
+	}
+	s.users[key] = u
+	return nil, nil
+}
+
+func (s *Server) metaBucket(key string) (interface{}, *apiError) {
+	b, ok := s.buckets[key]
+	if !ok {
+		return nil, errNoSuchKey
+	}
+	return &metaResponse{
+		Key:   "bucket:" + key,
+		Ver:   metaVer{Tag: "_fake_" + b.id, Ver: 1},
+		Mtime: b.created.Format(metaTimeFmt),
+		Data: map[string]interface{}{
+			"bucket":          b.metaBucket(),
+			"owner":           b.owner,
+			"creation_time":   b.created.Format(metaTimeFmt),
+			"linked":          "true",
+			"has_bucket_info": "false",
+		},
+	}, nil
+}
+
+// metaBucketInstance - key is bucket:bucket_id.
+func (s *Server) metaBucketInstance(key string) (interface{}, *apiError) {
+	i := strings.LastIndex(key, ":")
+	if i < 0 {
+		return nil, errNoSuchKey
+	}
+	b, ok := s.buckets[key[:i]]
+	if !ok || b.id != key[i+1:] {
+		return nil, errNoSuchKey
+	}
+	return &metaResponse{
+		Key:   "bucket.instance:" + key,
+		Ver:   metaVer{Tag: "_fake_" + b.id, Ver: 1},
+		Mtime: b.created.Format(metaTimeFmt),
+		Data: map[string]interface{}{
+			"bucket_info": map[string]interface{}{
+				"bucket":             b.metaBucket(),
+				"creation_time":      b.created.Format(metaTimeFmt),
+				"owner":              b.owner,
+				"flags":              0,
+				"zonegroup":          zoneGroupID,
+				"placement_rule":     "default-placement",
+				"has_instance_obj":   "true",
+				"quota":              b.quota,
+				"num_shards":         0,
+				"bi_shard_hash_type": 0,
+				"requester_pays":     "false",
+				"has_website":        "false",
+				"swift_versioning":   "false",
+				"swift_ver_location": "",
+				"index_type":         0,
+			},
+			"attrs": []map[string]string{},
+		},
+	}, nil
+}
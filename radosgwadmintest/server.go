@@ -0,0 +1,306 @@
+// Package radosgwadmintest provides an in-memory fake of the rados gateway admin
+// api, for testing code that uses radosgwadmin without a Ceph cluster.
+//
+//	srv := radosgwadmintest.NewServer()
+//	defer srv.Close()
+//	aa, err := radosgwadmin.NewAdminAPI(srv.Config())
+//
+// The fake serves /user (including ?key, ?subuser, ?caps and ?quota), /bucket
+// (stats, list, link, unlink, index, policy, object and bucket removal), /usage
+// and /metadata (gets, and puts of users), with the json shapes and error codes
+// of a real gateway.  An S3
+// bucket listing is served at the root, to check a user's credentials with.
+// Requests must be signed with AWS V2 or V4 signatures by a user with the
+// appropriate caps, as they would be for a real gateway.  The server starts with
+// one user, AdminUID, that has every cap.
+//
+// Buckets, objects and usage can't be created through the admin api, tests seed
+// them with AddBucket, AddObject and AddUsage.  SetKeyCreated backdates keys.
+package radosgwadmintest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myENA/radosgwadmin"
+)
+
+// Credentials of the admin user the server starts with.
+const (
+	AdminUID       = "admin"
+	AdminAccessKey = "0555b35654ad1656d804"
+	AdminSecretKey = "h7GhxuBLTrlhVUyxSPUKUV8r/2EI4ngqJxD7iBdBYLhwluN30JaT3Q=="
+)
+
+// Server - a fake gateway.  It is safe for concurrent use.
+type Server struct {
+	// URL - base url of the gateway, e.g. http://127.0.0.1:41234
+	URL string
+	// AdminPath - path the admin api is served under, "admin".  Set it to ""
+	// to serve it at the root.
+	AdminPath string
+	// Region - if set, V4 signatures must be scoped to this region.
+	Region string
+
+	srv *httptest.Server
+	now func() time.Time
+
+	mu      sync.Mutex
+	users   map[string]*user   // by uid, tenant$uid for tenanted users
+	keys    map[string]string  // s3 access key to uid
+	buckets map[string]*bucket // by name
+	records []UsageRecord
+	seq     int
+}
+
+// NewServer - Server factory method.  Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		AdminPath: "admin",
+		now:       time.Now,
+		users:     make(map[string]*user),
+		keys:      make(map[string]string),
+		buckets:   make(map[string]*bucket),
+	}
+	admin := s.newUser("", AdminUID, "Admin")
+	admin.Keys = []s3Key{{
+		User:       AdminUID,
+		AccessKey:  AdminAccessKey,
+		SecretKey:  AdminSecretKey,
+		Active:     true,
+		CreateDate: s.now().UTC().Format(keyDateFormat),
+	}}
+	for _, t := range capTypes {
+		admin.caps[t] = capRead | capWrite
+	}
+	s.keys[AdminAccessKey] = AdminUID
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close - shut down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Config - configuration for an AdminAPI that talks to this server as the admin
+// user.
+func (s *Server) Config() *radosgwadmin.Config {
+	return &radosgwadmin.Config{
+		ServerURL:       s.URL,
+		AdminPath:       s.AdminPath,
+		AccessKeyID:     AdminAccessKey,
+		SecretAccessKey: AdminSecretKey,
+	}
+}
+
+// apiError - an error response.
+type apiError struct {
+	status int
+	code   string
+}
+
+var (
+	errAccessDenied          = &apiError{http.StatusForbidden, "AccessDenied"}
+	errInvalidAccessKeyID    = &apiError{http.StatusForbidden, "InvalidAccessKeyId"}
+	errSignatureDoesNotMatch = &apiError{http.StatusForbidden, "SignatureDoesNotMatch"}
+	errRequestTimeTooSkewed  = &apiError{http.StatusForbidden, "RequestTimeTooSkewed"}
+	errInvalidArgument       = &apiError{http.StatusBadRequest, "InvalidArgument"}
+	errInvalidCapability     = &apiError{http.StatusBadRequest, "InvalidCapability"}
+	errInvalidKeyType        = &apiError{http.StatusBadRequest, "InvalidKeyType"}
+	errInvalidAccess         = &apiError{http.StatusBadRequest, "InvalidAccess"}
+	errNoSuchUser            = &apiError{http.StatusNotFound, "NoSuchUser"}
+	errNoSuchSubUser         = &apiError{http.StatusNotFound, "NoSuchSubUser"}
+	errNoSuchBucket          = &apiError{http.StatusNotFound, "NoSuchBucket"}
+	errNoSuchKey             = &apiError{http.StatusNotFound, "NoSuchKey"}
+	errUserAlreadyExists     = &apiError{http.StatusConflict, "UserAlreadyExists"}
+	errEmailExists           = &apiError{http.StatusConflict, "EmailExists"}
+	errKeyExists             = &apiError{http.StatusConflict, "KeyExists"}
+	errSubUserExists         = &apiError{http.StatusConflict, "SubuserExists"}
+	errBucketNotEmpty        = &apiError{http.StatusConflict, "BucketNotEmpty"}
+	errUserSuspended         = &apiError{http.StatusForbidden, "UserSuspended"}
+	errMethodNotAllowed      = &apiError{http.StatusMethodNotAllowed, "MethodNotAllowed"}
+)
+
+// rawJSON - a response body that is written as is.
+type rawJSON []byte
+
+// handler - serves one resource.  q is the parsed query, a nil result means an
+// empty response.
+type handler func(r *http.Request, q url.Values) (interface{}, *apiError)
+
+// ServeHTTP - implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	reqID := fmt.Sprintf("tx%021x-%010x-fake", s.seq, s.now().Unix())
+	w.Header().Set("X-Amz-Request-Id", reqID)
+
+	result, aerr := s.serve(r)
+	if aerr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(aerr.status)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"Code":      aerr.code,
+			"RequestId": reqID,
+			"HostId":    "fake-default-default",
+		})
+		return
+	}
+	switch res := result.(type) {
+	case nil:
+	case rawJSON:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(res)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
+
+func (s *Server) serve(r *http.Request) (interface{}, *apiError) {
+	prefix := "/"
+	if p := strings.Trim(s.AdminPath, "/"); p != "" {
+		prefix += p + "/"
+	}
+	listBuckets := r.URL.Path == "/" && r.Method == http.MethodGet
+	if !strings.HasPrefix(r.URL.Path, prefix) && !listBuckets {
+		return nil, errNoSuchBucket
+	}
+	resource := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	body, err := readBody(r)
+	if err != nil {
+		return nil, errInvalidArgument
+	}
+	caller, aerr := s.authenticate(r, body)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if listBuckets {
+		return s.listAllMyBuckets(caller), nil
+	}
+
+	q := r.URL.Query()
+	var capType string
+	var h handler
+	switch {
+	case resource == "user":
+		capType, h = "users", s.userHandler(q)
+	case resource == "bucket":
+		capType, h = "buckets", s.bucketHandler(q)
+	case resource == "usage":
+		capType, h = "usage", s.usageHandler
+	case resource == "metadata" || strings.HasPrefix(resource, "metadata/"):
+		capType, h = "metadata", s.metadataHandler(strings.TrimPrefix(strings.TrimPrefix(resource, "metadata"), "/"))
+	default:
+		return nil, errMethodNotAllowed
+	}
+	if aerr := s.checkCap(caller, capType, r.Method); aerr != nil {
+		return nil, aerr
+	}
+	return h(r, q)
+}
+
+// methods - route on the http method.
+func methods(get, put, post, del handler) handler {
+	return func(r *http.Request, q url.Values) (interface{}, *apiError) {
+		var h handler
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			h = get
+		case http.MethodPut:
+			h = put
+		case http.MethodPost:
+			h = post
+		case http.MethodDelete:
+			h = del
+		}
+		if h == nil {
+			return nil, errMethodNotAllowed
+		}
+		return h(r, q)
+	}
+}
+
+// checkCap - reads need the read cap for the resource type, anything else needs
+// write.
+func (s *Server) checkCap(uid, capType, method string) *apiError {
+	u := s.users[uid]
+	need := capWrite
+	if method == http.MethodGet || method == http.MethodHead {
+		need = capRead
+	}
+	if u == nil || u.caps[capType]&need == 0 {
+		return errAccessDenied
+	}
+	return nil
+}
+
+// boolParam - the value of a boolean query parameter, def if it's missing.
+func boolParam(q url.Values, name string, def bool) bool {
+	if _, ok := q[name]; !ok {
+		return def
+	}
+	switch strings.ToLower(q.Get(name)) {
+	case "", "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
+// intParam - the value of an integer query parameter, and whether it was set.
+func intParam(q url.Values, name string) (int64, bool, *apiError) {
+	v := q.Get(name)
+	if v == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false, errInvalidArgument
+	}
+	return n, true, nil
+}
+
+const (
+	upperAlnum = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	alnum      = upperAlnum + "abcdefghijklmnopqrstuvwxyz"
+)
+
+// randomString - n characters from chars, like the gateway's generated keys.
+func randomString(n int, chars string) string {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(chars)))
+	for i := range b {
+		r, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = chars[r.Int64()]
+	}
+	return string(b)
+}
+
+func (s *Server) newAccessKey() string {
+	for {
+		k := randomString(20, upperAlnum)
+		if _, ok := s.keys[k]; !ok {
+			return k
+		}
+	}
+}
+
+func newSecretKey() string {
+	return randomString(40, alnum)
+}
@@ -0,0 +1,443 @@
+package radosgwadmintest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	rgw "github.com/myENA/radosgwadmin"
+	awsauth "github.com/smartystreets/go-aws-auth"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServerSuite struct {
+	suite.Suite
+	mode rgw.SigningMode
+	srv  *Server
+	aa   *rgw.AdminAPI
+	ctx  context.Context
+}
+
+func (ss *ServerSuite) SetupTest() {
+	ss.srv = NewServer()
+	ss.aa = ss.newAdminAPI(AdminAccessKey, AdminSecretKey)
+	ss.ctx = context.Background()
+}
+
+func (ss *ServerSuite) TearDownTest() {
+	ss.srv.Close()
+}
+
+func (ss *ServerSuite) newAdminAPI(accessKey string, secretKey rgw.Secret) *rgw.AdminAPI {
+	cfg := ss.srv.Config()
+	cfg.AccessKeyID, cfg.SecretAccessKey = accessKey, secretKey
+	cfg.SigningMode = ss.mode
+	aa, err := rgw.NewAdminAPI(cfg)
+	ss.Require().NoError(err)
+	return aa
+}
+
+func (ss *ServerSuite) TestUsers() {
+	ui, err := ss.aa.UserCreate(ss.ctx, &rgw.UserCreateRequest{
+		UID:         rgw.UserID{ID: "alice"},
+		DisplayName: "Alice",
+		Email:       "alice@example.com",
+		UserCaps:    []rgw.UserCap{{Type: "buckets", Permission: "read"}},
+		MaxBuckets:  10,
+	})
+	ss.Require().NoError(err)
+	ss.Equal("alice", ui.UserID)
+	ss.Equal(10, ui.MaxBuckets)
+	ss.Equal([]rgw.UserCap{{Type: "buckets", Permission: "read"}}, ui.Caps)
+	ss.Require().Len(ui.Keys, 1)
+	ss.Len(ui.Keys[0].AccessKey, 20)
+	ss.Len(ui.Keys[0].SecretKey, 40)
+
+	_, err = ss.aa.UserCreate(ss.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: "alice"}, DisplayName: "Alice"})
+	ss.True(errors.Is(err, rgw.ErrUserAlreadyExists), "got %v", err)
+	_, err = ss.aa.UserCreate(ss.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: "bob"}, DisplayName: "Bob", Email: "alice@example.com"})
+	ss.True(errors.Is(err, rgw.ErrEmailExists), "got %v", err)
+	_, err = ss.aa.UserInfo(ss.ctx, rgw.UserID{ID: "nobody"}, false)
+	ss.True(errors.Is(err, rgw.ErrNoSuchUser), "got %v", err)
+
+	ui, err = ss.aa.UserModify(ss.ctx, &rgw.UserModifyRequest{UID: rgw.UserID{ID: "alice"}, DisplayName: "Alice A", Suspended: rgw.TrueRef})
+	ss.Require().NoError(err)
+	ss.Equal("Alice A", ui.DisplayName)
+	ss.True(ui.Suspended)
+	ss.Equal(rgw.OpAll, ui.OpMask)
+
+	ui, err = ss.aa.UserModify(ss.ctx, &rgw.UserModifyRequest{
+		UID:                 rgw.UserID{ID: "alice"},
+		OpMask:              rgw.OpRead,
+		DefaultPlacement:    "fast-placement",
+		PlacementTags:       []string{"ssd", "nvme"},
+		DefaultStorageClass: "COLD",
+		System:              rgw.TrueRef,
+	})
+	ss.Require().NoError(err)
+	ss.Equal(rgw.OpRead, ui.OpMask)
+	ss.Equal("fast-placement", ui.DefaultPlacement)
+	ss.Equal([]string{"ssd", "nvme"}, ui.PlacementTags)
+	ss.Equal("COLD", ui.DefaultStorageClass)
+	ss.True(ui.System)
+	ss.False(ui.Admin)
+	ss.Equal("Alice A", ui.DisplayName, "unset fields are left alone")
+
+	subs, err := ss.aa.SubUserCreate(ss.ctx, &rgw.SubUserCreateModifyRequest{
+		UID: rgw.UserID{ID: "alice"}, SubUser: "swift", Access: "readwrite", GenerateSecret: true,
+	})
+	ss.Require().NoError(err)
+	ss.Equal([]rgw.SubUser{{ID: "alice:swift", Permissions: "read-write"}}, subs)
+	_, err = ss.aa.SubUserCreate(ss.ctx, &rgw.SubUserCreateModifyRequest{UID: rgw.UserID{ID: "alice"}, SubUser: "swift"})
+	ss.True(errors.Is(err, rgw.ErrSubUserExists), "got %v", err)
+	subs, err = ss.aa.SubUserModify(ss.ctx, &rgw.SubUserCreateModifyRequest{UID: rgw.UserID{ID: "alice"}, SubUser: "swift", Access: "full"})
+	ss.Require().NoError(err)
+	ss.Equal("full-control", subs[0].Permissions)
+
+	keys, err := ss.aa.KeyCreate(ss.ctx, &rgw.KeyCreateRequest{UID: rgw.UserID{ID: "alice"}, AccessKey: "ALICEKEY", SecretKey: "alicesecret"})
+	ss.Require().NoError(err)
+	ss.Len(keys, 2)
+	_, err = ss.aa.KeyCreate(ss.ctx, &rgw.KeyCreateRequest{UID: rgw.UserID{ID: "alice"}, AccessKey: AdminAccessKey})
+	ss.True(errors.Is(err, rgw.ErrKeyExists), "got %v", err)
+
+	ui, err = ss.aa.UserInfo(ss.ctx, rgw.UserID{ID: "alice"}, true)
+	ss.Require().NoError(err)
+	ss.Len(ui.SwiftKeys, 1)
+	ss.Equal("alice:swift", ui.SwiftKeys[0].User)
+	ss.NotNil(ui.Stats)
+
+	ss.NoError(ss.aa.KeyRm(ss.ctx, &rgw.KeyRmRequest{AccessKey: "ALICEKEY"}))
+	err = ss.aa.KeyRm(ss.ctx, &rgw.KeyRmRequest{AccessKey: "ALICEKEY"})
+	ss.True(errors.Is(err, rgw.ErrInvalidAccessKeyID), "got %v", err)
+	ss.NoError(ss.aa.SubUserRm(ss.ctx, &rgw.SubUserRmRequest{UID: rgw.UserID{ID: "alice"}, SubUser: "swift"}))
+	ui, err = ss.aa.UserInfo(ss.ctx, rgw.UserID{ID: "alice"}, false)
+	ss.Require().NoError(err)
+	ss.Empty(ui.SubUsers)
+	ss.Empty(ui.SwiftKeys, "purge-keys defaults to true")
+	ss.Len(ui.Keys, 1)
+
+	caps, err := ss.aa.CapsAdd(ss.ctx, &rgw.UserCapsRequest{UID: rgw.UserID{ID: "alice"}, UserCaps: []rgw.UserCap{
+		{Type: "buckets", Permission: "write"}, {Type: "usage", Permission: "read"},
+	}})
+	ss.Require().NoError(err)
+	ss.Equal([]rgw.UserCap{{Type: "buckets", Permission: "*"}, {Type: "usage", Permission: "read"}}, caps)
+	caps, err = ss.aa.CapsRm(ss.ctx, &rgw.UserCapsRequest{UID: rgw.UserID{ID: "alice"}, UserCaps: []rgw.UserCap{
+		{Type: "buckets", Permission: "read"}, {Type: "usage", Permission: "*"},
+	}})
+	ss.Require().NoError(err)
+	ss.Equal([]rgw.UserCap{{Type: "buckets", Permission: "write"}}, caps)
+
+	ss.NoError(ss.aa.QuotaSet(ss.ctx, &rgw.QuotaSetRequest{UID: rgw.UserID{ID: "alice"}, QuotaType: "user", MaximumObjects: 100, Enabled: true}))
+	qm, err := ss.aa.QuotaUser(ss.ctx, rgw.UserID{ID: "alice"})
+	ss.Require().NoError(err)
+	ss.Equal(rgw.QuotaMeta{Enabled: true, MaxObjects: 100}, *qm)
+	quotas, err := ss.aa.Quotas(ss.ctx, rgw.UserID{ID: "alice"})
+	ss.Require().NoError(err)
+	ss.Equal(int64(-1), quotas.BucketQuota.MaxObjects)
+	ss.True(quotas.UserQuota.Enabled)
+
+	users, err := ss.aa.MListUsers(ss.ctx)
+	ss.Require().NoError(err)
+	ss.Equal([]string{"admin", "alice"}, users)
+	mu, err := ss.aa.MGetUser(ss.ctx, rgw.UserID{ID: "alice"})
+	ss.Require().NoError(err)
+	ss.Equal("user:alice", mu.Key)
+	ss.Equal("Alice A", mu.Data.DisplayName)
+
+	ss.NoError(ss.aa.UserRm(ss.ctx, rgw.UserID{ID: "alice"}, false))
+	_, err = ss.aa.UserInfo(ss.ctx, rgw.UserID{ID: "alice"}, false)
+	ss.True(errors.Is(err, rgw.ErrNoSuchUser), "got %v", err)
+}
+
+func (ss *ServerSuite) TestBuckets() {
+	_, err := ss.aa.UserCreate(ss.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: "alice"}, DisplayName: "Alice"})
+	ss.Require().NoError(err)
+	id, err := ss.srv.AddBucket("alice", "photos")
+	ss.Require().NoError(err)
+	_, err = ss.srv.AddBucket("admin", "backups")
+	ss.Require().NoError(err)
+	ss.Require().NoError(ss.srv.AddObject("photos", "cat.jpg", 5000))
+	ss.Require().NoError(ss.srv.AddObject("photos", "dog.jpg", 3000))
+
+	names, err := ss.aa.BucketList(ss.ctx, rgw.UserID{})
+	ss.Require().NoError(err)
+	ss.Equal([]string{"backups", "photos"}, names)
+	names, err = ss.aa.BucketList(ss.ctx, rgw.UserID{ID: "alice"})
+	ss.Require().NoError(err)
+	ss.Equal([]string{"photos"}, names)
+
+	stats, err := ss.aa.BucketStats(ss.ctx, rgw.UserID{}, rgw.BucketRef{Name: "photos"})
+	ss.Require().NoError(err)
+	ss.Require().Len(stats, 1)
+	ss.Equal(id, stats[0].ID)
+	ss.Equal("alice", stats[0].Owner)
+	ss.Equal(uint64(2), stats[0].Usage.RGWMain.NumObjects)
+	ss.Equal(uint64(8), stats[0].Usage.RGWMain.SizeKb)
+	ss.WithinDuration(time.Now(), time.Time(stats[0].Mtime), time.Hour*24)
+	stats, err = ss.aa.BucketStats(ss.ctx, rgw.UserID{}, rgw.BucketRef{})
+	ss.Require().NoError(err)
+	ss.Len(stats, 2)
+	_, err = ss.aa.BucketStats(ss.ctx, rgw.UserID{}, rgw.BucketRef{Name: "nope"})
+	ss.True(errors.Is(err, rgw.ErrNoSuchBucket), "got %v", err)
+
+	bir, err := ss.aa.BucketIndex(ss.ctx, &rgw.BucketIndexRequest{Bucket: "photos", CheckObjects: true})
+	ss.Require().NoError(err)
+	ss.Empty(bir.NewObjects)
+	ss.Equal(uint64(2), bir.Headers.ExistingHeader.Usage.RGWMain.NumObjects)
+
+	pol, err := ss.aa.BucketPolicy(ss.ctx, rgw.BucketRef{Name: "photos"}, "")
+	ss.Require().NoError(err)
+	ss.Equal("alice", pol.Owner.ID)
+	ss.Equal("Alice", pol.Owner.DisplayName)
+
+	mb, err := ss.aa.MGetBucket(ss.ctx, rgw.BucketRef{Name: "photos"})
+	ss.Require().NoError(err)
+	ss.Equal("bucket:photos", mb.Key)
+	ss.Equal(id, mb.Data.Bucket.BucketID)
+	instances, err := ss.aa.MListBucketInstances(ss.ctx)
+	ss.Require().NoError(err)
+	ss.Contains(instances, "photos:"+id)
+	mbi, err := ss.aa.MGetBucketInstance(ss.ctx, rgw.BucketRef{Name: "photos"}, id)
+	ss.Require().NoError(err)
+	ss.Equal("alice", mbi.Data.BucketInfo.Owner)
+
+	ss.NoError(ss.aa.BucketUnlink(ss.ctx, rgw.BucketRef{Name: "photos"}, rgw.UserID{ID: "alice"}))
+	ss.NoError(ss.aa.BucketLink(ss.ctx, rgw.BucketRef{Name: "photos"}, id, rgw.UserID{ID: "admin"}))
+	names, err = ss.aa.BucketList(ss.ctx, rgw.UserID{ID: "admin"})
+	ss.Require().NoError(err)
+	ss.Equal([]string{"backups", "photos"}, names)
+
+	ss.NoError(ss.aa.BucketObjectRm(ss.ctx, rgw.BucketRef{Name: "photos"}, "cat.jpg"))
+	err = ss.aa.BucketObjectRm(ss.ctx, rgw.BucketRef{Name: "photos"}, "cat.jpg")
+	ss.True(errors.Is(err, rgw.ErrNoSuchKey), "got %v", err)
+	err = ss.aa.BucketRm(ss.ctx, rgw.BucketRef{Name: "photos"}, false)
+	ss.True(errors.Is(err, rgw.ErrBucketNotEmpty), "got %v", err)
+	ss.NoError(ss.aa.BucketRm(ss.ctx, rgw.BucketRef{Name: "photos"}, true))
+	_, err = ss.aa.MGetBucket(ss.ctx, rgw.BucketRef{Name: "photos"})
+	ss.True(errors.Is(err, rgw.ErrNoSuchKey), "got %v", err)
+}
+
+func (ss *ServerSuite) TestLookup() {
+	ui, err := ss.aa.UserCreate(ss.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: "alice"}, DisplayName: "Alice", Email: "alice@example.com"})
+	ss.Require().NoError(err)
+
+	byKey, err := ss.aa.UserInfoByAccessKey(ss.ctx, ui.Keys[0].AccessKey, false)
+	ss.Require().NoError(err)
+	ss.Equal(ui, byKey)
+	_, err = ss.aa.UserInfoByAccessKey(ss.ctx, "NOSUCHKEY", false)
+	ss.True(errors.Is(err, rgw.ErrNoSuchUser), "got %v", err)
+
+	// The fake has no email lookup, so this scans the metadata.
+	byEmail, err := ss.aa.UserInfoByEmail(ss.ctx, "Alice@Example.com", false)
+	ss.Require().NoError(err)
+	ss.Equal(ui, byEmail)
+	_, err = ss.aa.UserInfoByEmail(ss.ctx, "bob@example.com", false)
+	ss.True(errors.Is(err, rgw.ErrNoSuchUser), "got %v", err)
+}
+
+func (ss *ServerSuite) TestMetadataPut() {
+	ui, err := ss.aa.UserCreate(ss.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: "alice"}, DisplayName: "Alice"})
+	ss.Require().NoError(err)
+	uid := rgw.UserID{ID: "alice"}
+
+	mu, err := ss.aa.MGetUser(ss.ctx, uid)
+	ss.Require().NoError(err)
+	mu.Data.DisplayName = "Alice A"
+	ss.Require().NoError(ss.aa.MPutUser(ss.ctx, uid, mu))
+
+	// The keys survive the round trip, and still work.
+	after, err := ss.aa.UserInfo(ss.ctx, uid, false)
+	ss.Require().NoError(err)
+	ss.Equal("Alice A", after.DisplayName)
+	ss.Equal(ui.Keys, after.Keys)
+	ss.Equal(ui.Keys[0].SecretKey.Reveal(), after.Keys[0].SecretKey.Reveal())
+	_, err = ss.newAdminAPI(ui.Keys[0].AccessKey, ui.Keys[0].SecretKey).UserInfo(ss.ctx, uid, false)
+	ss.True(errors.Is(err, rgw.ErrAccessDenied), "signed with the restored key, got %v", err)
+
+	err = ss.aa.MPutUser(ss.ctx, rgw.UserID{ID: "bob"}, mu)
+	ss.True(errors.Is(err, rgw.ErrInvalidArgument), "key and user must match, got %v", err)
+}
+
+func (ss *ServerSuite) TestPaging() {
+	cfg := ss.srv.Config()
+	cfg.SigningMode = ss.mode
+	cfg.ListPageSize = 2
+	aa, err := rgw.NewAdminAPI(cfg)
+	ss.Require().NoError(err)
+	want := []string{"admin"}
+	for _, uid := range []string{"u1", "u2", "u3", "u4"} {
+		_, err := aa.UserCreate(ss.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: uid}, DisplayName: uid})
+		ss.Require().NoError(err)
+		_, err = ss.srv.AddBucket(uid, "b-"+uid)
+		ss.Require().NoError(err)
+		want = append(want, uid)
+	}
+
+	var got []string
+	it := aa.Users(ss.ctx)
+	for it.Next() {
+		got = append(got, it.Value())
+	}
+	ss.NoError(it.Err())
+	ss.Equal(want, got)
+
+	got = nil
+	it = aa.Buckets(ss.ctx, rgw.UserID{})
+	for it.Next() {
+		got = append(got, it.Value())
+	}
+	ss.NoError(it.Err())
+	ss.Equal([]string{"b-u1", "b-u2", "b-u3", "b-u4"}, got)
+
+	page, err := aa.MListBucketInstancesPage(ss.ctx, "", 3)
+	ss.Require().NoError(err)
+	ss.True(page.Truncated)
+	ss.Equal(3, page.Count)
+	page, err = aa.MListBucketInstancesPage(ss.ctx, page.Marker, 3)
+	ss.Require().NoError(err)
+	ss.False(page.Truncated)
+	ss.Equal(1, page.Count)
+}
+
+func (ss *ServerSuite) TestTenants() {
+	acme := rgw.UserID{Tenant: "acme", ID: "alice"}
+	ui, err := ss.aa.UserCreate(ss.ctx, &rgw.UserCreateRequest{UID: acme, DisplayName: "Alice"})
+	ss.Require().NoError(err)
+	ss.Equal("acme", ui.Tenant)
+	ss.Equal("acme$alice", ui.UserID)
+
+	ui, err = ss.aa.UserInfo(ss.ctx, acme, false)
+	ss.Require().NoError(err)
+	ss.Equal("acme$alice", ui.UserID)
+	_, err = ss.aa.UserInfo(ss.ctx, rgw.UserID{ID: "alice"}, false)
+	ss.True(errors.Is(err, rgw.ErrNoSuchUser), "got %v", err)
+	mu, err := ss.aa.MGetUser(ss.ctx, acme)
+	ss.Require().NoError(err)
+	ss.Equal("user:acme$alice", mu.Key)
+
+	photos := rgw.BucketRef{Tenant: "acme", Name: "photos"}
+	_, err = ss.srv.AddBucket(acme.String(), photos.String())
+	ss.Require().NoError(err)
+	stats, err := ss.aa.BucketStats(ss.ctx, rgw.UserID{}, photos)
+	ss.Require().NoError(err)
+	ss.Equal("acme$alice", stats[0].Owner)
+	_, err = ss.aa.BucketStats(ss.ctx, rgw.UserID{}, rgw.BucketRef{Name: "photos"})
+	ss.True(errors.Is(err, rgw.ErrNoSuchBucket), "got %v", err)
+	ss.NoError(ss.aa.BucketRm(ss.ctx, photos, false))
+}
+
+func (ss *ServerSuite) TestUsage() {
+	hour := time.Date(2017, 3, 16, 4, 0, 0, 0, time.UTC)
+	ss.srv.AddUsage(UsageRecord{UID: "alice", Bucket: "photos", Category: "get_obj", Time: hour, BytesSent: 100, Ops: 2, SuccessfulOps: 2})
+	ss.srv.AddUsage(UsageRecord{UID: "alice", Bucket: "photos", Category: "get_obj", Time: hour.Add(time.Minute), BytesSent: 50, Ops: 1, SuccessfulOps: 1})
+	ss.srv.AddUsage(UsageRecord{UID: "alice", Bucket: "photos", Category: "put_obj", Time: hour.Add(time.Hour), BytesReceived: 300, Ops: 1, SuccessfulOps: 1})
+	ss.srv.AddUsage(UsageRecord{UID: "bob", Bucket: "docs", Category: "put_obj", Time: hour, BytesReceived: 10, Ops: 1})
+
+	ur, err := ss.aa.Usage(ss.ctx, &rgw.UsageRequest{UID: rgw.UserID{ID: "alice"}})
+	ss.Require().NoError(err)
+	ss.Require().Len(ur.Entries, 1)
+	ss.Require().Len(ur.Entries[0].Buckets, 2)
+	ss.Equal(hour, time.Time(ur.Entries[0].Buckets[0].Time).UTC())
+	ss.Equal(150, ur.Entries[0].Buckets[0].Categories[0].BytesSent)
+	ss.Require().Len(ur.Summary, 1)
+	ss.Equal(4, ur.Summary[0].Total.Ops)
+
+	ur, err = ss.aa.Usage(ss.ctx, &rgw.UsageRequest{Start: rgw.RadosTime(hour.Add(time.Hour)), ShowEntries: true})
+	ss.Require().NoError(err)
+	ss.Require().Len(ur.Entries, 1)
+	ss.Equal("put_obj", ur.Entries[0].Buckets[0].Categories[0].Category)
+
+	err = ss.aa.UsageTrim(ss.ctx, &rgw.TrimUsageRequest{})
+	ss.True(errors.Is(err, rgw.ErrInvalidArgument), "got %v", err)
+	ss.NoError(ss.aa.UsageTrim(ss.ctx, &rgw.TrimUsageRequest{UID: rgw.UserID{ID: "alice"}, End: rgw.RadosTime(hour.Add(time.Hour))}))
+	ur, err = ss.aa.Usage(ss.ctx, &rgw.UsageRequest{})
+	ss.Require().NoError(err)
+	ss.Len(ur.Entries, 2)
+	ss.Equal(2, ur.Summary[0].Total.Ops+ur.Summary[1].Total.Ops)
+}
+
+func (ss *ServerSuite) TestAuth() {
+	_, err := ss.newAdminAPI(AdminAccessKey, "wrong").MListUsers(ss.ctx)
+	ss.True(errors.Is(err, rgw.ErrSignatureDoesNotMatch), "got %v", err)
+	_, err = ss.newAdminAPI("NOSUCHKEY", "wrong").MListUsers(ss.ctx)
+	ss.True(errors.Is(err, rgw.ErrInvalidAccessKeyID), "got %v", err)
+
+	ui, err := ss.aa.UserCreate(ss.ctx, &rgw.UserCreateRequest{
+		UID: rgw.UserID{ID: "reader"}, DisplayName: "Reader", UserCaps: []rgw.UserCap{{Type: "users", Permission: "read"}},
+	})
+	ss.Require().NoError(err)
+	reader := ss.newAdminAPI(ui.Keys[0].AccessKey, ui.Keys[0].SecretKey)
+	_, err = reader.UserInfo(ss.ctx, rgw.UserID{ID: "admin"}, false)
+	ss.NoError(err)
+	_, err = reader.UserCreate(ss.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: "eve"}, DisplayName: "Eve"})
+	ss.True(errors.Is(err, rgw.ErrAccessDenied), "got %v", err)
+	_, err = reader.MListUsers(ss.ctx)
+	ss.True(errors.Is(err, rgw.ErrAccessDenied), "got %v", err)
+
+	ss.srv.now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, err = ss.aa.MListUsers(ss.ctx)
+	rerr := &rgw.RGWError{}
+	ss.Require().True(errors.As(err, &rerr), "got %v", err)
+	ss.Equal("RequestTimeTooSkewed", rerr.Code)
+}
+
+func (ss *ServerSuite) TestAuthContentMD5() {
+	if ss.mode != rgw.SigningModeV2 {
+		ss.T().Skip("V2 only")
+	}
+	cfg := ss.srv.Config()
+	newReq := func() *http.Request {
+		req, err := http.NewRequest(http.MethodGet, cfg.ServerURL+"/"+cfg.AdminPath+"/metadata/user", strings.NewReader("body"))
+		ss.Require().NoError(err)
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		return req
+	}
+	status := func(req *http.Request) int {
+		resp, err := http.DefaultClient.Do(req)
+		ss.Require().NoError(err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Without a Content-Md5 header the digest is signed as empty, as the
+	// gateway does, regardless of the body.
+	req := newReq()
+	mac := hmac.New(sha1.New, []byte(AdminSecretKey))
+	mac.Write([]byte("GET\n\n\n" + req.Header.Get("Date") + "\n" + req.URL.EscapedPath()))
+	req.Header.Set("Authorization", "AWS "+AdminAccessKey+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	ss.Equal(http.StatusOK, status(req))
+
+	req = newReq()
+	awsauth.SignS3(req, awsauth.Credentials{AccessKeyID: AdminAccessKey, SecretAccessKey: AdminSecretKey})
+	ss.Equal(http.StatusForbidden, status(req), "a digest of the body that isn't in a header is not signed")
+}
+
+func (ss *ServerSuite) TestRegion() {
+	if ss.mode != rgw.SigningModeV4 {
+		ss.T().Skip("V4 only")
+	}
+	ss.srv.Region = "eu-west-1"
+	_, err := ss.aa.MListUsers(ss.ctx)
+	ss.True(errors.Is(err, rgw.ErrSignatureDoesNotMatch), "got %v", err)
+
+	cfg := ss.srv.Config()
+	cfg.SigningMode, cfg.SigningRegion = rgw.SigningModeV4, "eu-west-1"
+	aa, err := rgw.NewAdminAPI(cfg)
+	ss.Require().NoError(err)
+	_, err = aa.MListUsers(ss.ctx)
+	ss.NoError(err)
+}
+
+func TestServerV2(t *testing.T) {
+	suite.Run(t, &ServerSuite{mode: rgw.SigningModeV2})
+}
+
+func TestServerV4(t *testing.T) {
+	suite.Run(t, &ServerSuite{mode: rgw.SigningModeV4})
+}
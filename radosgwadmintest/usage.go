@@ -0,0 +1,221 @@
+package radosgwadmintest
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// UsageRecord - usage of a bucket by a user in one category for one hour, as
+// the gateway logs it.
+type UsageRecord struct {
+	UID      string
+	Bucket   string
+	Category string    // e.g. "get_obj", "put_obj", "list_bucket"
+	Time     time.Time // truncated to the hour
+
+	BytesSent     int64
+	BytesReceived int64
+	Ops           int64
+	SuccessfulOps int64
+}
+
+// AddUsage - log usage.  Records for the same user, bucket, category and hour
+// are added together.
+func (s *Server) AddUsage(rec UsageRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec.Time = rec.Time.UTC().Truncate(time.Hour)
+	for i, r := range s.records {
+		if r.UID == rec.UID && r.Bucket == rec.Bucket && r.Category == rec.Category && r.Time.Equal(rec.Time) {
+			s.records[i].BytesSent += rec.BytesSent
+			s.records[i].BytesReceived += rec.BytesReceived
+			s.records[i].Ops += rec.Ops
+			s.records[i].SuccessfulOps += rec.SuccessfulOps
+			return
+		}
+	}
+	s.records = append(s.records, rec)
+}
+
+type usageCategory struct {
+	Category      string `json:"category"`
+	BytesSent     int64  `json:"bytes_sent"`
+	BytesReceived int64  `json:"bytes_received"`
+	Ops           int64  `json:"ops"`
+	SuccessfulOps int64  `json:"successful_ops"`
+}
+
+type usageTotal struct {
+	BytesSent     int64 `json:"bytes_sent"`
+	BytesReceived int64 `json:"bytes_received"`
+	Ops           int64 `json:"ops"`
+	SuccessfulOps int64 `json:"successful_ops"`
+}
+
+type usageBucket struct {
+	Bucket     string          `json:"bucket"`
+	Time       string          `json:"time"`
+	Epoch      int64           `json:"epoch"`
+	Owner      string          `json:"owner"`
+	Categories []usageCategory `json:"categories"`
+}
+
+type usageEntry struct {
+	User    string        `json:"user"`
+	Buckets []usageBucket `json:"buckets"`
+}
+
+type usageSummary struct {
+	User       string          `json:"user"`
+	Categories []usageCategory `json:"categories"`
+	Total      usageTotal      `json:"total"`
+}
+
+// parseUsageTime - the gateway accepts "2006-01-02 15:04:05", "2006-01-02" or
+// seconds since the epoch, in UTC.
+func parseUsageTime(v string) (time.Time, *apiError) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(n, 0).UTC(), nil
+	}
+	return time.Time{}, errInvalidArgument
+}
+
+// selectUsage - records matching the uid, start and end parameters.
+func (s *Server) selectUsage(q url.Values) ([]UsageRecord, *apiError) {
+	start, aerr := parseUsageTime(q.Get("start"))
+	if aerr != nil {
+		return nil, aerr
+	}
+	end, aerr := parseUsageTime(q.Get("end"))
+	if aerr != nil {
+		return nil, aerr
+	}
+	uid := q.Get("uid")
+	var out []UsageRecord
+	for _, r := range s.records {
+		if (uid == "" || r.UID == uid) && !r.Time.Before(start) && (end.IsZero() || r.Time.Before(end)) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) usageHandler(r *http.Request, q url.Values) (interface{}, *apiError) {
+	switch r.Method {
+	case http.MethodGet:
+		return s.usageGet(q)
+	case http.MethodDelete:
+		return s.usageTrim(q)
+	}
+	return nil, errMethodNotAllowed
+}
+
+func (s *Server) usageGet(q url.Values) (interface{}, *apiError) {
+	recs, aerr := s.selectUsage(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		a, b := recs[i], recs[j]
+		switch {
+		case a.UID != b.UID:
+			return a.UID < b.UID
+		case a.Bucket != b.Bucket:
+			return a.Bucket < b.Bucket
+		case !a.Time.Equal(b.Time):
+			return a.Time.Before(b.Time)
+		}
+		return a.Category < b.Category
+	})
+
+	entries := []*usageEntry{}
+	summary := []*usageSummary{}
+	var entry *usageEntry
+	var sum *usageSummary
+	for _, r := range recs {
+		if entry == nil || entry.User != r.UID {
+			entry = &usageEntry{User: r.UID, Buckets: []usageBucket{}}
+			entries = append(entries, entry)
+			sum = &usageSummary{User: r.UID, Categories: []usageCategory{}}
+			summary = append(summary, sum)
+		}
+		timestamp := r.Time.Format(metaTimeFmt)
+		if n := len(entry.Buckets); n == 0 || entry.Buckets[n-1].Bucket != r.Bucket || entry.Buckets[n-1].Time != timestamp {
+			owner := ""
+			if b, ok := s.buckets[r.Bucket]; ok {
+				owner = b.owner
+			}
+			entry.Buckets = append(entry.Buckets, usageBucket{
+				Bucket:     r.Bucket,
+				Time:       timestamp,
+				Epoch:      r.Time.Unix(),
+				Owner:      owner,
+				Categories: []usageCategory{},
+			})
+		}
+		cat := usageCategory{r.Category, r.BytesSent, r.BytesReceived, r.Ops, r.SuccessfulOps}
+		b := &entry.Buckets[len(entry.Buckets)-1]
+		b.Categories = append(b.Categories, cat)
+
+		found := false
+		for i := range sum.Categories {
+			if c := &sum.Categories[i]; c.Category == r.Category {
+				c.BytesSent += r.BytesSent
+				c.BytesReceived += r.BytesReceived
+				c.Ops += r.Ops
+				c.SuccessfulOps += r.SuccessfulOps
+				found = true
+			}
+		}
+		if !found {
+			sum.Categories = append(sum.Categories, cat)
+		}
+		sum.Total.BytesSent += r.BytesSent
+		sum.Total.BytesReceived += r.BytesReceived
+		sum.Total.Ops += r.Ops
+		sum.Total.SuccessfulOps += r.SuccessfulOps
+	}
+
+	resp := map[string]interface{}{}
+	if boolParam(q, "show-entries", true) {
+		resp["entries"] = entries
+	}
+	if boolParam(q, "show-summary", true) {
+		resp["summary"] = summary
+	}
+	return resp, nil
+}
+
+// usageTrim - trimming everyone's usage needs remove-all.
+func (s *Server) usageTrim(q url.Values) (interface{}, *apiError) {
+	if q.Get("uid") == "" && !boolParam(q, "remove-all", false) {
+		return nil, errInvalidArgument
+	}
+	trim, aerr := s.selectUsage(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	keep := s.records[:0]
+	for _, r := range s.records {
+		trimmed := false
+		for _, t := range trim {
+			trimmed = trimmed || r == t
+		}
+		if !trimmed {
+			keep = append(keep, r)
+		}
+	}
+	s.records = keep
+	return nil, nil
+}
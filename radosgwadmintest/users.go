@@ -0,0 +1,852 @@
+package radosgwadmintest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	rgw "github.com/myENA/radosgwadmin"
+)
+
+// Cap bits.
+const (
+	capRead = 1 << iota
+	capWrite
+)
+
+// capTypes - the cap types the gateway knows about.
+var capTypes = []string{
+	"accounts", "amz-cache", "bilog", "buckets", "datalog", "info", "mdlog",
+	"metadata", "oidc-provider", "ratelimit", "roles", "usage", "user-info-without-keys",
+	"user-policy", "users", "zone",
+}
+
+// keyDateFormat - how the gateway formats key creation dates.
+const keyDateFormat = "2006-01-02T15:04:05.000000Z"
+
+type s3Key struct {
+	User       string `json:"user"`
+	AccessKey  string `json:"access_key"`
+	SecretKey  string `json:"secret_key"`
+	Active     bool   `json:"active"`
+	CreateDate string `json:"create_date"`
+}
+
+type swiftKey struct {
+	User       string `json:"user"`
+	SecretKey  string `json:"secret_key"`
+	Active     bool   `json:"active"`
+	CreateDate string `json:"create_date"`
+}
+
+type subUser struct {
+	ID          string `json:"id"`
+	Permissions string `json:"permissions"`
+}
+
+type userCap struct {
+	Type string `json:"type"`
+	Perm string `json:"perm"`
+}
+
+type quota struct {
+	Enabled    bool  `json:"enabled"`
+	CheckOnRaw bool  `json:"check_on_raw"`
+	MaxSize    int64 `json:"max_size"`
+	MaxSizeKb  int64 `json:"max_size_kb"`
+	MaxObjects int64 `json:"max_objects"`
+}
+
+func defaultQuota() quota {
+	return quota{MaxSize: -1, MaxSizeKb: 0, MaxObjects: -1}
+}
+
+type userStats struct {
+	Size           int64 `json:"size"`
+	SizeActual     int64 `json:"size_actual"`
+	SizeUtilized   int64 `json:"size_utilized"`
+	SizeKb         int64 `json:"size_kb"`
+	SizeKbActual   int64 `json:"size_kb_actual"`
+	SizeKbUtilized int64 `json:"size_kb_utilized"`
+	NumObjects     int64 `json:"num_objects"`
+}
+
+// user - a user, as the gateway returns it.
+type user struct {
+	Tenant              string        `json:"tenant"`
+	UserID              string        `json:"user_id"`
+	DisplayName         string        `json:"display_name"`
+	Email               string        `json:"email"`
+	Suspended           int           `json:"suspended"`
+	MaxBuckets          int           `json:"max_buckets"`
+	Auid                int           `json:"auid"`
+	SubUsers            []subUser     `json:"subusers"`
+	Keys                []s3Key       `json:"keys"`
+	SwiftKeys           []swiftKey    `json:"swift_keys"`
+	Caps                []userCap     `json:"caps"`
+	OpMask              string        `json:"op_mask"`
+	DefaultPlacement    string        `json:"default_placement"`
+	DefaultStorageClass string        `json:"default_storage_class"`
+	PlacementTags       []string      `json:"placement_tags"`
+	BucketQuota         quota         `json:"bucket_quota"`
+	UserQuota           quota         `json:"user_quota"`
+	TempURLKeys         []interface{} `json:"temp_url_keys"`
+	Type                string        `json:"type"`
+	System              bool          `json:"system,omitempty"`
+	Admin               bool          `json:"admin,omitempty"`
+	Stats               *userStats    `json:"stats,omitempty"`
+
+	caps map[string]int
+}
+
+// newUser - add a user with the defaults the gateway gives them.
+func (s *Server) newUser(tenant, uid, displayName string) *user {
+	u := &user{
+		Tenant:        tenant,
+		UserID:        uid,
+		DisplayName:   displayName,
+		MaxBuckets:    1000,
+		SubUsers:      []subUser{},
+		Keys:          []s3Key{},
+		SwiftKeys:     []swiftKey{},
+		OpMask:        "read, write, delete",
+		PlacementTags: []string{},
+		BucketQuota:   defaultQuota(),
+		UserQuota:     defaultQuota(),
+		TempURLKeys:   []interface{}{},
+		Type:          "rgw",
+		caps:          make(map[string]int),
+	}
+	if tenant != "" {
+		u.UserID = tenant + "$" + uid
+	}
+	s.users[u.UserID] = u
+	return u
+}
+
+// info - the user as returned by the gateway.
+func (s *Server) info(u *user, stats bool) *user {
+	out := *u
+	out.Caps = capList(u.caps)
+	out.Stats = nil
+	if stats {
+		st := &userStats{}
+		for _, b := range s.buckets {
+			if b.owner == u.UserID {
+				size, n := b.usage()
+				st.Size += size
+				st.SizeActual += roundUp(size)
+				st.SizeUtilized += size
+				st.NumObjects += n
+			}
+		}
+		st.SizeKb = (st.Size + 1023) / 1024
+		st.SizeKbActual = st.SizeActual / 1024
+		st.SizeKbUtilized = st.SizeKb
+		out.Stats = st
+	}
+	return &out
+}
+
+func (s *Server) userHandler(q url.Values) handler {
+	switch {
+	case hasParam(q, "key"):
+		return methods(nil, s.keyCreate, s.keyModify, s.keyRm)
+	case hasParam(q, "subuser"):
+		// ?subuser names the resource, and subuser=name the subuser.
+		q["subuser"] = nonEmpty(q["subuser"])
+		return methods(nil, s.subUserCreate, s.subUserModify, s.subUserRm)
+	case hasParam(q, "caps"):
+		return methods(nil, s.capsAdd, nil, s.capsRm)
+	case hasParam(q, "quota"):
+		return methods(s.quotaGet, s.quotaSet, nil, nil)
+	}
+	return methods(s.userInfo, s.userCreate, s.userModify, s.userRm)
+}
+
+func nonEmpty(vs []string) []string {
+	out := []string{}
+	for _, v := range vs {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func hasParam(q url.Values, name string) bool {
+	_, ok := q[name]
+	return ok
+}
+
+// lookupUser - the user named by the uid parameter.
+func (s *Server) lookupUser(q url.Values) (*user, *apiError) {
+	uid := q.Get("uid")
+	if uid == "" {
+		return nil, errInvalidArgument
+	}
+	u, ok := s.users[uid]
+	if !ok {
+		return nil, errNoSuchUser
+	}
+	return u, nil
+}
+
+// userInfo - by uid or, failing that, access-key.  Like older gateways, the
+// fake does not support looking up by email.
+func (s *Server) userInfo(r *http.Request, q url.Values) (interface{}, *apiError) {
+	if ak := q.Get("access-key"); ak != "" && q.Get("uid") == "" {
+		uid, ok := s.keys[ak]
+		if !ok {
+			return nil, errNoSuchUser
+		}
+		q.Set("uid", uid)
+	}
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	return s.info(u, boolParam(q, "stats", false)), nil
+}
+
+func (s *Server) userCreate(r *http.Request, q url.Values) (interface{}, *apiError) {
+	uid, tenant := q.Get("uid"), q.Get("tenant")
+	if i := strings.Index(uid, "$"); i >= 0 && tenant == "" {
+		tenant, uid = uid[:i], uid[i+1:]
+	}
+	if uid == "" || q.Get("display-name") == "" {
+		return nil, errInvalidArgument
+	}
+	id := uid
+	if tenant != "" {
+		id = tenant + "$" + uid
+	}
+	if _, ok := s.users[id]; ok {
+		return nil, errUserAlreadyExists
+	}
+	email := q.Get("email")
+	if email != "" && s.emailTaken(email, "") {
+		return nil, errEmailExists
+	}
+	caps, aerr := parseCaps(q.Get("user-caps"))
+	if aerr != nil {
+		return nil, aerr
+	}
+	keyType := q.Get("key-type")
+	if keyType != "" && keyType != "s3" && keyType != "swift" {
+		return nil, errInvalidKeyType
+	}
+	accessKey, secretKey := q.Get("access-key"), q.Get("secret-key")
+	if accessKey != "" {
+		if _, ok := s.keys[accessKey]; ok {
+			return nil, errKeyExists
+		}
+	}
+	maxBuckets, set, aerr := intParam(q, "max-buckets")
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	opMask, aerr := opMaskParam(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	u := s.newUser(tenant, uid, q.Get("display-name"))
+	u.Email = email
+	if set {
+		u.MaxBuckets = int(maxBuckets)
+	}
+	if boolParam(q, "suspended", false) {
+		u.Suspended = 1
+	}
+	if opMask != "" {
+		u.OpMask = opMask
+	}
+	setPlacement(u, q)
+	u.Admin = boolParam(q, "admin", false)
+	u.System = boolParam(q, "system", false)
+	for t, perm := range caps {
+		u.caps[t] |= perm
+	}
+	if keyType == "swift" {
+		if secretKey != "" || boolParam(q, "generate-key", true) {
+			s.setSwiftKey(u, u.UserID, secretKey)
+		}
+	} else if accessKey != "" || secretKey != "" || boolParam(q, "generate-key", true) {
+		s.setS3Key(u, u.UserID, accessKey, secretKey)
+	}
+	return s.info(u, false), nil
+}
+
+func (s *Server) userModify(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	email := q.Get("email")
+	if email != "" && s.emailTaken(email, u.UserID) {
+		return nil, errEmailExists
+	}
+	caps, aerr := parseCaps(q.Get("user-caps"))
+	if aerr != nil {
+		return nil, aerr
+	}
+	keyType := q.Get("key-type")
+	if keyType != "" && keyType != "s3" && keyType != "swift" {
+		return nil, errInvalidKeyType
+	}
+	accessKey, secretKey := q.Get("access-key"), q.Get("secret-key")
+	if owner, ok := s.keys[accessKey]; ok && owner != u.UserID {
+		return nil, errKeyExists
+	}
+	maxBuckets, set, aerr := intParam(q, "max-buckets")
+	if aerr != nil {
+		return nil, aerr
+	}
+	opMask, aerr := opMaskParam(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	if dn := q.Get("display-name"); dn != "" {
+		u.DisplayName = dn
+	}
+	if email != "" {
+		u.Email = email
+	}
+	if set {
+		u.MaxBuckets = int(maxBuckets)
+	}
+	if hasParam(q, "suspended") {
+		u.Suspended = 0
+		if boolParam(q, "suspended", false) {
+			u.Suspended = 1
+		}
+	}
+	if opMask != "" {
+		u.OpMask = opMask
+	}
+	setPlacement(u, q)
+	if hasParam(q, "admin") {
+		u.Admin = boolParam(q, "admin", false)
+	}
+	if hasParam(q, "system") {
+		u.System = boolParam(q, "system", false)
+	}
+	for t, perm := range caps {
+		u.caps[t] |= perm
+	}
+	if keyType == "swift" {
+		if secretKey != "" || boolParam(q, "generate-key", false) {
+			s.setSwiftKey(u, u.UserID, secretKey)
+		}
+	} else if accessKey != "" || secretKey != "" || boolParam(q, "generate-key", false) {
+		s.setS3Key(u, u.UserID, accessKey, secretKey)
+	}
+	return s.info(u, false), nil
+}
+
+// opMaskParam - the op-mask param in the gateway's format, or "" if not given.
+func opMaskParam(q url.Values) (string, *apiError) {
+	if q.Get("op-mask") == "" {
+		return "", nil
+	}
+	m, err := rgw.ParseOpMask(q.Get("op-mask"))
+	if err != nil {
+		return "", errInvalidArgument
+	}
+	return m.String(), nil
+}
+
+// setPlacement - set the placement params that are given.
+func setPlacement(u *user, q url.Values) {
+	if v := q.Get("default-placement"); v != "" {
+		u.DefaultPlacement = v
+	}
+	if v := q.Get("placement-tags"); v != "" {
+		u.PlacementTags = strings.Split(v, ",")
+	}
+	if v := q.Get("default-storage-class"); v != "" {
+		u.DefaultStorageClass = v
+	}
+}
+
+// userRm - a user that owns buckets can only be removed with purge-data, which
+// removes the buckets too.
+func (s *Server) userRm(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	purge := boolParam(q, "purge-data", false)
+	for _, b := range s.buckets {
+		if b.owner == u.UserID && !purge {
+			return nil, errBucketNotEmpty
+		}
+	}
+	for name, b := range s.buckets {
+		if b.owner == u.UserID {
+			delete(s.buckets, name)
+		}
+	}
+	for _, k := range u.Keys {
+		delete(s.keys, k.AccessKey)
+	}
+	delete(s.users, u.UserID)
+	return nil, nil
+}
+
+func (s *Server) emailTaken(email, except string) bool {
+	for id, u := range s.users {
+		if id != except && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// setS3Key - add or update an s3 key of u, owned by owner (the user or one of
+// its subusers), generating whatever is missing.
+func (s *Server) setS3Key(u *user, owner, accessKey, secretKey string) {
+	if secretKey == "" {
+		secretKey = newSecretKey()
+	}
+	if accessKey != "" {
+		for i, k := range u.Keys {
+			if k.AccessKey == accessKey {
+				u.Keys[i].SecretKey = secretKey
+				return
+			}
+		}
+	} else {
+		accessKey = s.newAccessKey()
+	}
+	u.Keys = append(u.Keys, s3Key{
+		User:       owner,
+		AccessKey:  accessKey,
+		SecretKey:  secretKey,
+		Active:     true,
+		CreateDate: s.now().UTC().Format(keyDateFormat),
+	})
+	s.keys[accessKey] = u.UserID
+}
+
+// setSwiftKey - set the swift key of owner, there is only ever one.
+func (s *Server) setSwiftKey(u *user, owner, secretKey string) {
+	if secretKey == "" {
+		secretKey = newSecretKey()
+	}
+	for i, k := range u.SwiftKeys {
+		if k.User == owner {
+			u.SwiftKeys[i].SecretKey = secretKey
+			return
+		}
+	}
+	u.SwiftKeys = append(u.SwiftKeys, swiftKey{
+		User:       owner,
+		SecretKey:  secretKey,
+		Active:     true,
+		CreateDate: s.now().UTC().Format(keyDateFormat),
+	})
+}
+
+// subUserID - subuser ids are uid:name, the name alone is accepted too.
+func subUserID(u *user, name string) string {
+	if strings.HasPrefix(name, u.UserID+":") {
+		return name
+	}
+	return u.UserID + ":" + name
+}
+
+// keyOwner - the uid, or the subuser if there is one.
+func keyOwner(u *user, q url.Values) string {
+	if sub := q.Get("subuser"); sub != "" {
+		return subUserID(u, sub)
+	}
+	return u.UserID
+}
+
+func (s *Server) findSubUser(u *user, id string) int {
+	for i, su := range u.SubUsers {
+		if su.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// accessPermissions - the access parameter as the gateway reports it.
+var accessPermissions = map[string]string{
+	"read":      "read",
+	"write":     "write",
+	"readwrite": "read-write",
+	"full":      "full-control",
+}
+
+func (s *Server) subUserCreate(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if q.Get("subuser") == "" {
+		return nil, errInvalidArgument
+	}
+	id := subUserID(u, q.Get("subuser"))
+	if s.findSubUser(u, id) >= 0 {
+		return nil, errSubUserExists
+	}
+	perm := "<none>"
+	if access := q.Get("access"); access != "" {
+		var ok bool
+		if perm, ok = accessPermissions[access]; !ok {
+			return nil, errInvalidAccess
+		}
+	}
+	if aerr := s.subUserKey(u, id, q); aerr != nil {
+		return nil, aerr
+	}
+	u.SubUsers = append(u.SubUsers, subUser{ID: id, Permissions: perm})
+	return u.SubUsers, nil
+}
+
+func (s *Server) subUserModify(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	i := s.findSubUser(u, subUserID(u, q.Get("subuser")))
+	if i < 0 {
+		return nil, errNoSuchSubUser
+	}
+	if access := q.Get("access"); access != "" {
+		perm, ok := accessPermissions[access]
+		if !ok {
+			return nil, errInvalidAccess
+		}
+		u.SubUsers[i].Permissions = perm
+	}
+	if aerr := s.subUserKey(u, u.SubUsers[i].ID, q); aerr != nil {
+		return nil, aerr
+	}
+	return u.SubUsers, nil
+}
+
+// subUserKey - create the subuser's key if asked to, a swift key by default.
+func (s *Server) subUserKey(u *user, id string, q url.Values) *apiError {
+	secret := q.Get("secret-key")
+	if secret == "" && !boolParam(q, "generate-secret", false) {
+		return nil
+	}
+	switch q.Get("key-type") {
+	case "", "swift":
+		s.setSwiftKey(u, id, secret)
+	case "s3":
+		s.setS3Key(u, id, "", secret)
+	default:
+		return errInvalidKeyType
+	}
+	return nil
+}
+
+func (s *Server) subUserRm(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	id := subUserID(u, q.Get("subuser"))
+	i := s.findSubUser(u, id)
+	if i < 0 {
+		return nil, errNoSuchSubUser
+	}
+	u.SubUsers = append(u.SubUsers[:i], u.SubUsers[i+1:]...)
+	if boolParam(q, "purge-keys", true) {
+		s.removeKeys(u, func(owner string) bool { return owner == id })
+	}
+	return nil, nil
+}
+
+// removeKeys - remove the s3 and swift keys whose owner matches.
+func (s *Server) removeKeys(u *user, match func(owner string) bool) {
+	keys := u.Keys[:0]
+	for _, k := range u.Keys {
+		if match(k.User) {
+			delete(s.keys, k.AccessKey)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	u.Keys = keys
+	swiftKeys := u.SwiftKeys[:0]
+	for _, k := range u.SwiftKeys {
+		if !match(k.User) {
+			swiftKeys = append(swiftKeys, k)
+		}
+	}
+	u.SwiftKeys = swiftKeys
+}
+
+func (s *Server) keyCreate(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	owner := keyOwner(u, q)
+	if owner != u.UserID && s.findSubUser(u, owner) < 0 {
+		return nil, errNoSuchSubUser
+	}
+	keyType := q.Get("key-type")
+	if keyType == "" {
+		keyType = "s3"
+		if owner != u.UserID {
+			keyType = "swift"
+		}
+	}
+	accessKey, secretKey := q.Get("access-key"), q.Get("secret-key")
+	generate := boolParam(q, "generate-key", true)
+	switch keyType {
+	case "s3":
+		if uid, ok := s.keys[accessKey]; ok && uid != u.UserID {
+			return nil, errKeyExists
+		}
+		if accessKey != "" || secretKey != "" || generate {
+			s.setS3Key(u, owner, accessKey, secretKey)
+		}
+		return u.Keys, nil
+	case "swift":
+		if secretKey != "" || generate {
+			s.setSwiftKey(u, owner, secretKey)
+		}
+		return u.SwiftKeys, nil
+	}
+	return nil, errInvalidKeyType
+}
+
+// keyModify - s3 keys are found by access-key, swift keys by their owner.
+func (s *Server) keyModify(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	set := func(active *bool) {
+		if hasParam(q, "active") {
+			*active = boolParam(q, "active", false)
+		}
+	}
+	switch q.Get("key-type") {
+	case "", "s3":
+		for i := range u.Keys {
+			if u.Keys[i].AccessKey == q.Get("access-key") {
+				set(&u.Keys[i].Active)
+				return u.Keys, nil
+			}
+		}
+		return nil, errInvalidAccessKeyID
+	case "swift":
+		owner := keyOwner(u, q)
+		for i := range u.SwiftKeys {
+			if u.SwiftKeys[i].User == owner {
+				set(&u.SwiftKeys[i].Active)
+				return u.SwiftKeys, nil
+			}
+		}
+		return nil, errNoSuchKey
+	}
+	return nil, errInvalidKeyType
+}
+
+// SetKeyCreated - backdate the creation of an s3 key, e.g. to test key age
+// checks.
+func (s *Server) SetKeyCreated(accessKey string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uid, ok := s.keys[accessKey]
+	if !ok {
+		return fmt.Errorf("no key %s", accessKey)
+	}
+	u := s.users[uid]
+	for i := range u.Keys {
+		if u.Keys[i].AccessKey == accessKey {
+			u.Keys[i].CreateDate = t.UTC().Format(keyDateFormat)
+		}
+	}
+	return nil
+}
+
+func (s *Server) keyRm(r *http.Request, q url.Values) (interface{}, *apiError) {
+	if q.Get("key-type") == "swift" {
+		u, aerr := s.lookupUser(q)
+		if aerr != nil {
+			return nil, aerr
+		}
+		owner := keyOwner(u, q)
+		swiftKeys := u.SwiftKeys[:0]
+		for _, k := range u.SwiftKeys {
+			if k.User != owner {
+				swiftKeys = append(swiftKeys, k)
+			}
+		}
+		u.SwiftKeys = swiftKeys
+		return nil, nil
+	}
+	accessKey := q.Get("access-key")
+	uid, ok := s.keys[accessKey]
+	if !ok || (q.Get("uid") != "" && q.Get("uid") != uid) {
+		return nil, errInvalidAccessKeyID
+	}
+	u := s.users[uid]
+	for i, k := range u.Keys {
+		if k.AccessKey == accessKey {
+			u.Keys = append(u.Keys[:i], u.Keys[i+1:]...)
+			break
+		}
+	}
+	delete(s.keys, accessKey)
+	return nil, nil
+}
+
+// parseCaps - parse a user-caps parameter, e.g. "users=read;buckets=*".
+func parseCaps(s string) (map[string]int, *apiError) {
+	caps := make(map[string]int)
+	if s == "" {
+		return caps, nil
+	}
+	for _, c := range strings.Split(s, ";") {
+		kv := strings.SplitN(c, "=", 2)
+		if len(kv) != 2 {
+			return nil, errInvalidCapability
+		}
+		t := strings.TrimSpace(kv[0])
+		known := false
+		for _, ct := range capTypes {
+			known = known || ct == t
+		}
+		if !known {
+			return nil, errInvalidCapability
+		}
+		perm := 0
+		for _, p := range strings.Split(kv[1], ",") {
+			switch strings.TrimSpace(p) {
+			case "*":
+				perm |= capRead | capWrite
+			case "read":
+				perm |= capRead
+			case "write":
+				perm |= capWrite
+			default:
+				return nil, errInvalidCapability
+			}
+		}
+		caps[t] |= perm
+	}
+	return caps, nil
+}
+
+// capList - caps as the gateway reports them, sorted by type.
+func capList(caps map[string]int) []userCap {
+	out := []userCap{}
+	for t, perm := range caps {
+		switch perm {
+		case capRead:
+			out = append(out, userCap{t, "read"})
+		case capWrite:
+			out = append(out, userCap{t, "write"})
+		case capRead | capWrite:
+			out = append(out, userCap{t, "*"})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+func (s *Server) capsAdd(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	caps, aerr := parseCaps(q.Get("user-caps"))
+	if aerr != nil {
+		return nil, aerr
+	}
+	for t, perm := range caps {
+		u.caps[t] |= perm
+	}
+	return capList(u.caps), nil
+}
+
+func (s *Server) capsRm(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	caps, aerr := parseCaps(q.Get("user-caps"))
+	if aerr != nil {
+		return nil, aerr
+	}
+	for t, perm := range caps {
+		if u.caps[t] &^= perm; u.caps[t] == 0 {
+			delete(u.caps, t)
+		}
+	}
+	return capList(u.caps), nil
+}
+
+func (s *Server) quotaGet(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	switch q.Get("quota-type") {
+	case "user":
+		return u.UserQuota, nil
+	case "bucket":
+		return u.BucketQuota, nil
+	case "":
+		return map[string]quota{"bucket_quota": u.BucketQuota, "user_quota": u.UserQuota}, nil
+	}
+	return nil, errInvalidArgument
+}
+
+// quotaSet - parameters that are left out keep their current value.
+func (s *Server) quotaSet(r *http.Request, q url.Values) (interface{}, *apiError) {
+	u, aerr := s.lookupUser(q)
+	if aerr != nil {
+		return nil, aerr
+	}
+	var qt *quota
+	switch q.Get("quota-type") {
+	case "user":
+		qt = &u.UserQuota
+	case "bucket":
+		qt = &u.BucketQuota
+	default:
+		return nil, errInvalidArgument
+	}
+	nq := *qt
+	if n, ok, aerr := intParam(q, "max-objects"); aerr != nil {
+		return nil, aerr
+	} else if ok {
+		nq.MaxObjects = n
+	}
+	if n, ok, aerr := intParam(q, "max-size-kb"); aerr != nil {
+		return nil, aerr
+	} else if ok {
+		// Reported rounded up from bytes, as the gateway does, so -1 comes back
+		// as 0.
+		nq.MaxSize = n * 1024
+		nq.MaxSizeKb = (nq.MaxSize + 1023) / 1024
+	}
+	if n, ok, aerr := intParam(q, "max-size"); aerr != nil {
+		return nil, aerr
+	} else if ok {
+		nq.MaxSize, nq.MaxSizeKb = n, (n+1023)/1024
+	}
+	if hasParam(q, "enabled") {
+		nq.Enabled = boolParam(q, "enabled", false)
+	}
+	*qt = nq
+	return nil, nil
+}
@@ -1,10 +1,28 @@
 package radosgwadmin
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
+	"net/url"
+	"sync"
 	"time"
+
+	"github.com/myENA/restclient"
+)
+
+var (
+	tzMu sync.RWMutex
+	tz   = time.Local // see SetTimeZone
 )
 
+// defaultLocation - the location bucket format times are decoded in.
+func defaultLocation() *time.Location {
+	tzMu.RLock()
+	defer tzMu.RUnlock()
+	return tz
+}
+
 // RadosTime - This knows how to use the date time formats returned
 // from the rados gateway.
 type RadosTime time.Time
@@ -15,17 +33,59 @@ const RadosTimeFormat string = "2006-01-02 15:04:05.000000Z07:00"
 // RadosBucketTimeFormat - used for bucket calls
 const RadosBucketTimeFormat string = "2006-01-02 15:04:05.000000"
 
-// UnmarshalText - implements TextUnmarshaler
+// RadosQueryTimeFormat - used for times sent to the gateway in query strings,
+// always in UTC.
+const RadosQueryTimeFormat string = "2006-01-02 15:04:05"
+
+// timeNow - for tests.
+var timeNow = time.Now
+
+// LastNHours - the start and end times of the n hours up to now, for usage
+// requests, e.g.
+//
+//	ureq := &UsageRequest{UID: UserID{ID: "someone"}}
+//	ureq.Start, ureq.End = LastNHours(24)
+func LastNHours(n int) (start, end RadosTime) {
+	now := timeNow()
+	return RadosTime(now.Add(-time.Duration(n) * time.Hour)), RadosTime(now)
+}
+
+// UnmarshalText - implements TextUnmarshaler.  Bucket format times carry no
+// zone, they are taken to be in the location set with SetTimeZone.  The mtime
+// of BucketStatsResponse and the metadata responses, decoded by an AdminAPI, is
+// in its Location instead.  RFC 3339 times, as newer
+// releases use for key creation dates, are accepted too.
 func (rt *RadosTime) UnmarshalText(text []byte) error {
-	var err error
-	t := (*time.Time)(rt)
-	*t, err = time.Parse(RadosTimeFormat, string(text))
+	return rt.parse(string(text), defaultLocation())
+}
+
+// parse - parse s, taking bucket format times to be in loc.
+func (rt *RadosTime) parse(s string, loc *time.Location) error {
+	t, err := time.Parse(RadosTimeFormat, s)
 	if err != nil {
-		*t, err = time.ParseInLocation(RadosBucketTimeFormat, string(text), tz)
+		if t, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			t, err = time.ParseInLocation(RadosBucketTimeFormat, s, loc)
+		}
 	}
+	*rt = RadosTime(t)
 	return err
 }
 
+// IsZero - true for the zero time, which is omitted from query strings.
+func (rt RadosTime) IsZero() bool {
+	return time.Time(rt).IsZero()
+}
+
+// EncodeValues - implements query.Encoder, so that RadosTime fields of request
+// structs are sent in RadosQueryTimeFormat.  Zero times are left out.
+func (rt RadosTime) EncodeValues(key string, v *url.Values) error {
+	if rt.IsZero() {
+		return nil
+	}
+	v.Set(key, time.Time(rt).UTC().Format(RadosQueryTimeFormat))
+	return nil
+}
+
 // MarshalText - implements TextMarshaler
 func (rt RadosTime) MarshalText() ([]byte, error) {
 	t := time.Time(rt)
@@ -36,3 +96,54 @@ func (rt RadosTime) MarshalText() ([]byte, error) {
 	b := make([]byte, 0, len(RadosTimeFormat))
 	return t.AppendFormat(b, RadosTimeFormat), nil
 }
+
+// locDecoder - restclient.CustomDecoder that decodes a response into v, with
+// the bucket format mtime of BucketStatsResponse and the metadata responses in
+// loc.  AdminAPI uses one per call, so that its zone applies to them.
+type locDecoder struct {
+	v   interface{}
+	loc *time.Location
+}
+
+// Decode - implements restclient.CustomDecoder
+func (ld *locDecoder) Decode(r io.Reader) error {
+	if cd, ok := ld.v.(restclient.CustomDecoder); ok {
+		return cd.Decode(r)
+	}
+	var data json.RawMessage
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, ld.v); err != nil {
+		return err
+	}
+	switch v := ld.v.(type) {
+	case *BucketStatsResponse:
+		return mtimeInLocation(data, &v.Mtime, ld.loc)
+	case *[]BucketStatsResponse:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		for i := range *v {
+			if err := mtimeInLocation(items[i], &(*v)[i].Mtime, ld.loc); err != nil {
+				return err
+			}
+		}
+	case interface{ meta() *MetaResponse }:
+		return mtimeInLocation(data, &v.meta().Mtime, ld.loc)
+	}
+	return nil
+}
+
+// mtimeInLocation - decode the mtime member of the json object data into rt,
+// taking a bucket format time to be in loc.
+func mtimeInLocation(data []byte, rt *RadosTime, loc *time.Location) error {
+	var v struct {
+		Mtime *string `json:"mtime"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil || v.Mtime == nil {
+		return err
+	}
+	return rt.parse(*v.Mtime, loc)
+}
@@ -0,0 +1,384 @@
+package radosgwadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// UserSpec - the desired state of a user, for Reconcile.  Optional fields left
+// nil are not managed: whatever the user has is kept.
+type UserSpec struct {
+	UID         UserID
+	DisplayName string
+	// Email - left as is when empty, the gateway can't clear an email.
+	Email string
+	// MaxBuckets - zero is an error, as neither UserCreate nor UserModify can
+	// send it.
+	MaxBuckets *int
+	Suspended  bool
+	// Caps - the exact caps the user should have, if not nil.
+	Caps []UserCap
+	// UserQuota, BucketQuota - zero limits are not sent by QuotaSet, use -1 for
+	// no limit.
+	UserQuota   *QuotaMeta
+	BucketQuota *QuotaMeta
+	// SubUsers - subusers the user should have, if not nil.  Others are only
+	// removed with ReconcileOptions.Prune.
+	SubUsers []SubUserSpec
+	// Keys - the number of s3 keys the user itself (not its subusers) should
+	// have, if not nil.  Missing keys are generated.  Extra keys are only
+	// removed with ReconcileOptions.Prune, the last listed first.
+	Keys *int
+}
+
+// SubUserSpec - a subuser, by name without the "uid:" prefix, and its access
+// level, one of "read", "write", "readwrite" and "full".
+type SubUserSpec struct {
+	Name   string
+	Access string
+}
+
+// ReconcileOptions - options for Reconcile.
+type ReconcileOptions struct {
+	// DryRun - only plan, make no changes.
+	DryRun bool
+	// Prune - remove subusers and keys that are not in the spec.
+	Prune bool
+}
+
+// ReconcileStep - one call that brings a user closer to its spec.
+type ReconcileStep struct {
+	// Operation - name of the AdminAPI method, e.g. "CapsAdd".
+	Operation string
+	// Request - the request passed to it, e.g. a *UserCapsRequest.
+	Request interface{}
+	// Changes - human readable description of what the call changes, one line
+	// each, starting with "+", "-" or "~".
+	Changes []string
+
+	apply func(ctx context.Context) error
+}
+
+// ReconcilePlan - the steps that bring a user to its spec, in the order they are
+// applied.  An empty plan means the user already matches.
+type ReconcilePlan struct {
+	UID     UserID
+	Create  bool
+	Steps   []ReconcileStep
+	Applied int // number of steps applied successfully
+}
+
+// Empty - true if there is nothing to do.
+func (p *ReconcilePlan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Diff - the changes in the plan, one per line, under the user they apply to.
+func (p *ReconcilePlan) Diff() string {
+	var b strings.Builder
+	mark := "~"
+	if p.Create {
+		mark = "+"
+	}
+	fmt.Fprintf(&b, "%s user %s\n", mark, p.UID)
+	if p.Empty() {
+		b.WriteString("  (no changes)\n")
+	}
+	for _, s := range p.Steps {
+		for _, c := range s.Changes {
+			b.WriteString("  ")
+			b.WriteString(c)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// String - implements fmt.Stringer, see Diff.
+func (p *ReconcilePlan) String() string {
+	return p.Diff()
+}
+
+// Apply - make the calls in the plan, stopping at the first error.  Steps that
+// were applied before, by an earlier Apply, are skipped.
+func (p *ReconcilePlan) Apply(ctx context.Context) error {
+	for p.Applied < len(p.Steps) {
+		s := p.Steps[p.Applied]
+		if err := s.apply(ctx); err != nil {
+			return fmt.Errorf("reconcile %s: %s: %w", p.UID, s.Operation, err)
+		}
+		p.Applied++
+	}
+	return nil
+}
+
+// Reconcile - compare a user with its spec and, unless opts.DryRun is set, make
+// the calls needed to bring it in line.  Reconciling a user that matches its spec
+// does nothing, so it is safe to run repeatedly.  The plan is returned in either
+// case, with the steps applied counted in Applied.
+func (aa *AdminAPI) Reconcile(ctx context.Context, spec *UserSpec, opts ReconcileOptions) (*ReconcilePlan, error) {
+	p, err := aa.planReconcile(ctx, spec, opts)
+	if err != nil || opts.DryRun {
+		return p, err
+	}
+	return p, p.Apply(ctx)
+}
+
+func (aa *AdminAPI) planReconcile(ctx context.Context, spec *UserSpec, opts ReconcileOptions) (*ReconcilePlan, error) {
+	if spec.UID.ID == "" {
+		return nil, errors.New("radosgwadmin: UserSpec.UID is required")
+	}
+	if spec.MaxBuckets != nil && *spec.MaxBuckets == 0 {
+		return nil, errors.New("radosgwadmin: UserSpec.MaxBuckets can't be 0, UserCreate and UserModify don't send it")
+	}
+	p := &ReconcilePlan{UID: spec.UID}
+	uid := spec.UID
+	var want CapSet
+	if spec.Caps != nil {
+		var err error
+		if want, err = NewCapSet(spec.Caps); err != nil {
+			return nil, fmt.Errorf("radosgwadmin: UserSpec.Caps: %w", err)
+		}
+	}
+
+	cur, err := aa.UserInfo(ctx, spec.UID, false)
+	switch {
+	case errors.Is(err, ErrNoSuchUser):
+		p.Create = true
+		cur = p.create(aa, spec)
+	case err != nil:
+		return nil, err
+	default:
+		p.modify(aa, spec, cur)
+		if want != nil {
+			have, err := NewCapSet(cur.Caps)
+			if err != nil {
+				return nil, err
+			}
+			p.caps(aa, uid, have, want)
+		}
+	}
+
+	quotas := &Quotas{}
+	if !p.Create && (spec.UserQuota != nil || spec.BucketQuota != nil) {
+		if quotas, err = aa.Quotas(ctx, spec.UID); err != nil {
+			return nil, err
+		}
+	}
+	if spec.UserQuota != nil && (p.Create || !sameQuota(*spec.UserQuota, quotas.UserQuota)) {
+		p.quota(aa, uid, "user", quotas.UserQuota, *spec.UserQuota)
+	}
+	if spec.BucketQuota != nil && (p.Create || !sameQuota(*spec.BucketQuota, quotas.BucketQuota)) {
+		p.quota(aa, uid, "bucket", quotas.BucketQuota, *spec.BucketQuota)
+	}
+
+	if spec.SubUsers != nil {
+		p.subUsers(aa, uid, cur.SubUsers, spec.SubUsers, opts.Prune)
+	}
+	if spec.Keys != nil {
+		p.keys(aa, uid, cur.Keys, *spec.Keys, opts.Prune)
+	}
+	return p, nil
+}
+
+func (p *ReconcilePlan) add(op string, req interface{}, apply func(ctx context.Context) error, changes ...string) {
+	p.Steps = append(p.Steps, ReconcileStep{Operation: op, Request: req, Changes: changes, apply: apply})
+}
+
+// create - plan a UserCreate, and return what the user will look like after.
+func (p *ReconcilePlan) create(aa *AdminAPI, spec *UserSpec) *UserInfoResponse {
+	req := &UserCreateRequest{
+		UID:         spec.UID,
+		DisplayName: spec.DisplayName,
+		Email:       spec.Email,
+		Suspended:   spec.Suspended,
+		UserCaps:    spec.Caps,
+	}
+	changes := []string{fmt.Sprintf("+ display_name: %q", spec.DisplayName)}
+	if spec.Email != "" {
+		changes = append(changes, fmt.Sprintf("+ email: %q", spec.Email))
+	}
+	if spec.MaxBuckets != nil {
+		req.MaxBuckets = *spec.MaxBuckets
+		changes = append(changes, fmt.Sprintf("+ max_buckets: %d", *spec.MaxBuckets))
+	}
+	if spec.Suspended {
+		changes = append(changes, "+ suspended: true")
+	}
+	for _, c := range spec.Caps {
+		changes = append(changes, "+ cap "+c.String())
+	}
+	after := &UserInfoResponse{}
+	if spec.Keys != nil && *spec.Keys == 0 {
+		req.GenerateKey = FalseRef
+	} else {
+		// The gateway generates a key by default.
+		after.Keys = []UserKey{{User: req.UID.String()}}
+		changes = append(changes, "+ s3 key")
+	}
+	p.add("UserCreate", req, func(ctx context.Context) error {
+		_, err := aa.UserCreate(ctx, req)
+		return err
+	}, changes...)
+	return after
+}
+
+// modify - plan a UserModify for the basic attributes that differ.
+func (p *ReconcilePlan) modify(aa *AdminAPI, spec *UserSpec, cur *UserInfoResponse) {
+	req := &UserModifyRequest{UID: spec.UID}
+	var changes []string
+	if spec.DisplayName != "" && spec.DisplayName != cur.DisplayName {
+		req.DisplayName = spec.DisplayName
+		changes = append(changes, fmt.Sprintf("~ display_name: %q -> %q", cur.DisplayName, spec.DisplayName))
+	}
+	if spec.Email != "" && !strings.EqualFold(spec.Email, cur.Email) {
+		req.Email = spec.Email
+		changes = append(changes, fmt.Sprintf("~ email: %q -> %q", cur.Email, spec.Email))
+	}
+	if spec.MaxBuckets != nil && *spec.MaxBuckets != cur.MaxBuckets {
+		req.MaxBuckets = *spec.MaxBuckets
+		changes = append(changes, fmt.Sprintf("~ max_buckets: %d -> %d", cur.MaxBuckets, *spec.MaxBuckets))
+	}
+	if spec.Suspended != cur.Suspended {
+		req.Suspended = &spec.Suspended
+		changes = append(changes, fmt.Sprintf("~ suspended: %t -> %t", cur.Suspended, spec.Suspended))
+	}
+	if len(changes) == 0 {
+		return
+	}
+	p.add("UserModify", req, func(ctx context.Context) error {
+		_, err := aa.UserModify(ctx, req)
+		return err
+	}, changes...)
+}
+
+// caps - plan a CapsAdd and a CapsRm to get from have to want.
+func (p *ReconcilePlan) caps(aa *AdminAPI, uid UserID, have, want CapSet) {
+	add, rm := want.Difference(have).Caps(), have.Difference(want).Caps()
+	if len(add) > 0 {
+		req := &UserCapsRequest{UID: uid, UserCaps: add}
+		var changes []string
+		for _, c := range add {
+			changes = append(changes, "+ cap "+c.String())
+		}
+		p.add("CapsAdd", req, func(ctx context.Context) error {
+			_, err := aa.CapsAdd(ctx, req)
+			return err
+		}, changes...)
+	}
+	if len(rm) > 0 {
+		req := &UserCapsRequest{UID: uid, UserCaps: rm}
+		var changes []string
+		for _, c := range rm {
+			changes = append(changes, "- cap "+c.String())
+		}
+		p.add("CapsRm", req, func(ctx context.Context) error {
+			_, err := aa.CapsRm(ctx, req)
+			return err
+		}, changes...)
+	}
+}
+
+// sameQuota - whether a and b set the same limits.  Any limit of 0 or less is
+// no limit: the gateway takes -1, but reports an unlimited size as 0.
+func sameQuota(a, b QuotaMeta) bool {
+	return a.Enabled == b.Enabled &&
+		quotaLimit(a.MaxSizeKb) == quotaLimit(b.MaxSizeKb) &&
+		quotaLimit(a.MaxObjects) == quotaLimit(b.MaxObjects)
+}
+
+func quotaLimit(n int64) int64 {
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
+
+func quotaString(q QuotaMeta) string {
+	return fmt.Sprintf("enabled=%t max_size_kb=%d max_objects=%d", q.Enabled, q.MaxSizeKb, q.MaxObjects)
+}
+
+// quota - plan a QuotaSet.
+func (p *ReconcilePlan) quota(aa *AdminAPI, uid UserID, quotaType string, cur, want QuotaMeta) {
+	req := &QuotaSetRequest{
+		UID:            uid,
+		QuotaType:      quotaType,
+		MaximumObjects: int(want.MaxObjects),
+		MaximumSizeKb:  int(want.MaxSizeKb),
+		Enabled:        want.Enabled,
+	}
+	change := fmt.Sprintf("+ %s_quota: %s", quotaType, quotaString(want))
+	if !p.Create {
+		change = fmt.Sprintf("~ %s_quota: %s -> %s", quotaType, quotaString(cur), quotaString(want))
+	}
+	p.add("QuotaSet", req, func(ctx context.Context) error {
+		return aa.QuotaSet(ctx, req)
+	}, change)
+}
+
+// subUsers - plan creating, modifying and, with prune, removing subusers.
+func (p *ReconcilePlan) subUsers(aa *AdminAPI, uid UserID, cur []SubUser, want []SubUserSpec, prune bool) {
+	have := map[string]string{}
+	for _, su := range cur {
+		have[su.ID] = su.Permissions
+	}
+	wanted := map[string]bool{}
+	for _, sus := range want {
+		id := subUserID(uid, sus.Name)
+		wanted[id] = true
+		req := &SubUserCreateModifyRequest{UID: uid, SubUser: sus.Name, Access: sus.Access}
+		perms, ok := have[id]
+		switch {
+		case !ok:
+			p.add("SubUserCreate", req, func(ctx context.Context) error {
+				_, err := aa.SubUserCreate(ctx, req)
+				return err
+			}, fmt.Sprintf("+ subuser %s: %s", id, subUserPermissions(sus.Access)))
+		case perms != subUserPermissions(sus.Access):
+			p.add("SubUserModify", req, func(ctx context.Context) error {
+				_, err := aa.SubUserModify(ctx, req)
+				return err
+			}, fmt.Sprintf("~ subuser %s: %s -> %s", id, perms, subUserPermissions(sus.Access)))
+		}
+	}
+	if !prune {
+		return
+	}
+	for _, su := range cur {
+		if wanted[su.ID] {
+			continue
+		}
+		req := &SubUserRmRequest{UID: uid, SubUser: strings.TrimPrefix(su.ID, uid.String()+":")}
+		p.add("SubUserRm", req, func(ctx context.Context) error {
+			return aa.SubUserRm(ctx, req)
+		}, "- subuser "+su.ID)
+	}
+}
+
+// keys - plan generating or, with prune, removing the user's own s3 keys.
+func (p *ReconcilePlan) keys(aa *AdminAPI, uid UserID, cur []UserKey, want int, prune bool) {
+	var own []UserKey
+	for _, k := range cur {
+		if k.User == uid.String() {
+			own = append(own, k)
+		}
+	}
+	for i := len(own); i < want; i++ {
+		req := &KeyCreateRequest{UID: uid, KeyType: "s3"}
+		p.add("KeyCreate", req, func(ctx context.Context) error {
+			_, err := aa.KeyCreate(ctx, req)
+			return err
+		}, "+ s3 key")
+	}
+	if !prune {
+		return
+	}
+	for i := len(own) - 1; i >= want; i-- {
+		req := &KeyRmRequest{UID: uid, AccessKey: own[i].AccessKey, KeyType: "s3"}
+		p.add("KeyRm", req, func(ctx context.Context) error {
+			return aa.KeyRm(ctx, req)
+		}, "- s3 key "+own[i].AccessKey)
+	}
+}
@@ -0,0 +1,163 @@
+package radosgwadmin_test
+
+import (
+	"testing"
+
+	rgw "github.com/myENA/radosgwadmin"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReconcileSuite struct {
+	fakeSuite
+	spec *rgw.UserSpec
+}
+
+func intRef(i int) *int {
+	return &i
+}
+
+func (rs *ReconcileSuite) SetupTest() {
+	rs.fakeSuite.SetupTest()
+	rs.spec = &rgw.UserSpec{
+		UID:         rgw.UserID{ID: "carol"},
+		DisplayName: "Carol",
+		Email:       "carol@example.com",
+		MaxBuckets:  intRef(5),
+		Caps:        []rgw.UserCap{{Type: "buckets", Permission: "read"}},
+		UserQuota:   &rgw.QuotaMeta{Enabled: true, MaxSizeKb: 1024, MaxObjects: 100},
+		SubUsers:    []rgw.SubUserSpec{{Name: "app", Access: "readwrite"}},
+		Keys:        intRef(2),
+	}
+}
+
+func (rs *ReconcileSuite) operations(p *rgw.ReconcilePlan) []string {
+	var ops []string
+	for _, s := range p.Steps {
+		ops = append(ops, s.Operation)
+	}
+	return ops
+}
+
+func (rs *ReconcileSuite) TestCreate() {
+	p, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{DryRun: true})
+	rs.Require().NoError(err)
+	rs.True(p.Create)
+	rs.Equal([]string{"UserCreate", "QuotaSet", "SubUserCreate", "KeyCreate"}, rs.operations(p))
+	rs.Equal(0, p.Applied)
+	_, err = rs.aa.UserInfo(rs.ctx, rs.spec.UID, false)
+	rs.True(err != nil, "a dry run must not create the user")
+
+	p, err = rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+	rs.Equal(len(p.Steps), p.Applied)
+
+	ui, err := rs.aa.UserInfo(rs.ctx, rs.spec.UID, false)
+	rs.Require().NoError(err)
+	rs.Equal("Carol", ui.DisplayName)
+	rs.Equal(5, ui.MaxBuckets)
+	rs.Equal(rs.spec.Caps, ui.Caps)
+	rs.Equal([]rgw.SubUser{{ID: "carol:app", Permissions: "read-write"}}, ui.SubUsers)
+	rs.Len(ui.Keys, 2)
+	q, err := rs.aa.QuotaUser(rs.ctx, rs.spec.UID)
+	rs.Require().NoError(err)
+	rs.Equal(*rs.spec.UserQuota, *q)
+
+	p, err = rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+	rs.True(p.Empty(), "reconciling again should do nothing, got:\n%s", p)
+	rs.Equal("~ user carol\n  (no changes)\n", p.Diff())
+}
+
+func (rs *ReconcileSuite) TestModify() {
+	_, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+
+	rs.spec.DisplayName = "Carol C."
+	rs.spec.Suspended = true
+	rs.spec.Caps = []rgw.UserCap{{Type: "buckets", Permission: "write"}, {Type: "usage", Permission: "*"}}
+	rs.spec.UserQuota.MaxObjects = 200
+	rs.spec.SubUsers[0].Access = "full"
+	p, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{DryRun: true})
+	rs.Require().NoError(err)
+	rs.Equal(`~ user carol
+  ~ display_name: "Carol" -> "Carol C."
+  ~ suspended: false -> true
+  + cap buckets=write
+  + cap usage=*
+  - cap buckets=read
+  ~ user_quota: enabled=true max_size_kb=1024 max_objects=100 -> enabled=true max_size_kb=1024 max_objects=200
+  ~ subuser carol:app: read-write -> full-control
+`, p.Diff())
+
+	rs.Require().NoError(p.Apply(rs.ctx))
+	p, err = rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+	rs.True(p.Empty(), "got:\n%s", p)
+}
+
+func (rs *ReconcileSuite) TestBadCaps() {
+	_, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+
+	// A typo must not be read as no permission, and remove the cap.
+	rs.spec.Caps = []rgw.UserCap{{Type: "buckets", Permission: "rw"}}
+	p, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Error(err)
+	rs.Nil(p)
+	ui, err := rs.aa.UserInfo(rs.ctx, rs.spec.UID, false)
+	rs.Require().NoError(err)
+	rs.Equal([]rgw.UserCap{{Type: "buckets", Permission: "read"}}, ui.Caps)
+}
+
+func (rs *ReconcileSuite) TestZeroMaxBuckets() {
+	_, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+
+	// Zero can't be sent, so rather than plan nothing and claim a match, this
+	// fails.
+	rs.spec.MaxBuckets = intRef(0)
+	p, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{DryRun: true})
+	rs.Error(err)
+	rs.Nil(p)
+}
+
+func (rs *ReconcileSuite) TestUnlimitedQuota() {
+	// As Provision fills in unset limits.  The gateway reports the size as 0.
+	rs.spec.UserQuota = &rgw.QuotaMeta{Enabled: true, MaxSizeKb: -1, MaxObjects: 100}
+	rs.spec.BucketQuota = &rgw.QuotaMeta{Enabled: true, MaxSizeKb: 2048, MaxObjects: -1}
+	_, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+	q, err := rs.aa.QuotaUser(rs.ctx, rs.spec.UID)
+	rs.Require().NoError(err)
+	rs.Equal(rgw.QuotaMeta{Enabled: true, MaxSizeKb: 0, MaxObjects: 100}, *q)
+
+	p, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+	rs.True(p.Empty(), "got:\n%s", p)
+}
+
+func (rs *ReconcileSuite) TestPrune() {
+	_, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+	ui, err := rs.aa.UserInfo(rs.ctx, rs.spec.UID, false)
+	rs.Require().NoError(err)
+
+	rs.spec.SubUsers = []rgw.SubUserSpec{}
+	rs.spec.Keys = intRef(1)
+	p, err := rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{})
+	rs.Require().NoError(err)
+	rs.True(p.Empty(), "extra subusers and keys are kept without Prune, got:\n%s", p)
+
+	p, err = rs.aa.Reconcile(rs.ctx, rs.spec, rgw.ReconcileOptions{Prune: true})
+	rs.Require().NoError(err)
+	rs.Equal([]string{"SubUserRm", "KeyRm"}, rs.operations(p))
+
+	after, err := rs.aa.UserInfo(rs.ctx, rs.spec.UID, false)
+	rs.Require().NoError(err)
+	rs.Empty(after.SubUsers)
+	rs.Equal([]rgw.UserKey{ui.Keys[0]}, after.Keys)
+}
+
+func TestReconcile(t *testing.T) {
+	suite.Run(t, new(ReconcileSuite))
+}
@@ -0,0 +1,124 @@
+package radosgwadmin
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/myENA/restclient"
+)
+
+// RetryPolicy - controls how AdminAPI retries requests that fail with a
+// connection error or a 500, 502, 503 or 504 response.
+//
+// Reads (GET requests, which covers UserInfo, BucketStats, Usage and the M*
+// metadata calls) are retried by default.  Mutations are only retried if the
+// context passed to the call was wrapped with WithRetry, since the gateway
+// may have applied the change before failing.  WithoutRetry turns retries off
+// for a single call.
+//
+// The delay before retry n is BaseDelay * 2^(n-1), capped at MaxDelay, with
+// jitter applied so that the actual delay lies between half and all of that.
+// A retry is never started if its delay would run past the context deadline.
+//
+// Retries happen inside the http client, so Config.ClientTimeout applies to a
+// call as a whole: all attempts and the delays between them.  Once it runs out
+// the call fails with the timeout error, even if attempts are left.  Allow for
+// MaxAttempts attempts plus their backoff when setting it.
+type RetryPolicy struct {
+	// MaxAttempts - total attempts per call, including the first.  Defaults to
+	// DefaultMaxAttempts, set to 1 to disable retries.  With several endpoints,
+	// each gets at least one attempt regardless.
+	MaxAttempts int
+	// BaseDelay - defaults to DefaultRetryBaseDelay
+	BaseDelay restclient.Duration
+	// MaxDelay - defaults to DefaultRetryMaxDelay
+	MaxDelay restclient.Duration
+}
+
+const (
+	// DefaultMaxAttempts - default for RetryPolicy.MaxAttempts
+	DefaultMaxAttempts = 3
+	// DefaultRetryBaseDelay - default for RetryPolicy.BaseDelay
+	DefaultRetryBaseDelay = 100 * time.Millisecond
+	// DefaultRetryMaxDelay - default for RetryPolicy.MaxDelay
+	DefaultRetryMaxDelay = 5 * time.Second
+)
+
+// withDefaults - returns a copy of rp with unset fields filled in.
+func (rp RetryPolicy) withDefaults() RetryPolicy {
+	if rp.MaxAttempts <= 0 {
+		rp.MaxAttempts = DefaultMaxAttempts
+	}
+	if rp.BaseDelay <= 0 {
+		rp.BaseDelay = restclient.Duration(DefaultRetryBaseDelay)
+	}
+	if rp.MaxDelay <= 0 {
+		rp.MaxDelay = restclient.Duration(DefaultRetryMaxDelay)
+	}
+	return rp
+}
+
+// backoff - the delay before retry number n, starting at 1.
+func (rp RetryPolicy) backoff(n int) time.Duration {
+	d := time.Duration(rp.BaseDelay)
+	for i := 1; i < n && d < time.Duration(rp.MaxDelay); i++ {
+		d *= 2
+	}
+	if d > time.Duration(rp.MaxDelay) {
+		d = time.Duration(rp.MaxDelay)
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+type retryKey struct{}
+
+// WithRetry - returns a context that marks the call made with it as safe to
+// retry.  Use this for mutations that you know to be idempotent, for example a
+// KeyCreate that specifies both the access and secret key.
+func WithRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+// WithoutRetry - returns a context that disables retries and failover for the
+// call made with it, even for reads.
+func WithoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, false)
+}
+
+// isRetryable - whether req may be sent more than once.  Reads are, unless the
+// caller said otherwise, mutations aren't, unless the caller said otherwise.
+func isRetryable(req *http.Request) bool {
+	if v, ok := req.Context().Value(retryKey{}).(bool); ok {
+		return v
+	}
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// retryableStatus - response codes that indicate a transient gateway problem.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// sleepCtx - wait for d, or until ctx is done.  Returns false in the latter case,
+// or right away if ctx has a deadline that is sooner than d.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if dl, ok := ctx.Deadline(); ok && time.Until(dl) < d {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,110 @@
+// Package rgwprom exports radosgwadmin client metrics to prometheus.
+//
+//	c := rgwprom.NewCollector(rgwprom.Opts{})
+//	prometheus.MustRegister(c)
+//	aa, err := radosgwadmin.NewAdminAPI(&radosgwadmin.Config{
+//		...
+//		Metrics: c,
+//	})
+//
+// Three metrics are exported, all labelled by operation (the AdminAPI method
+// name, e.g. "UserInfo") and http method:
+//
+//	radosgwadmin_requests_total            counter
+//	radosgwadmin_request_errors_total      counter, also labelled by RGW error code
+//	radosgwadmin_request_duration_seconds  histogram
+//
+// An operation is counted once however many times it was retried.
+package rgwprom
+
+import (
+	"github.com/myENA/radosgwadmin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Label names.
+const (
+	LabelOperation = "operation"
+	LabelMethod    = "method"
+	LabelCode      = "code"
+)
+
+// Opts - Collector options, the zero value is usable.
+type Opts struct {
+	// Namespace - metric name prefix, defaults to "radosgwadmin".
+	Namespace string
+	// Subsystem - optional, goes between Namespace and the metric name.
+	Subsystem string
+	// ConstLabels - added to every metric, e.g. to tell clusters apart.
+	ConstLabels prometheus.Labels
+	// Buckets - latency histogram buckets in seconds, defaults to
+	// prometheus.DefBuckets.
+	Buckets []float64
+}
+
+// Collector - implements both prometheus.Collector and
+// radosgwadmin.MetricsObserver.  One Collector may be shared by several
+// AdminAPIs, give each its own ConstLabels otherwise.
+type Collector struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+var _ radosgwadmin.MetricsObserver = (*Collector)(nil)
+
+// NewCollector - Collector factory method.
+func NewCollector(opts Opts) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "radosgwadmin"
+	}
+	labels := []string{LabelOperation, LabelMethod}
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "requests_total",
+			Help:        "Admin API operations performed.",
+			ConstLabels: opts.ConstLabels,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "request_errors_total",
+			Help:        "Admin API operations that failed, by RGW error code.",
+			ConstLabels: opts.ConstLabels,
+		}, append(labels, LabelCode)),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "request_duration_seconds",
+			Help:        "Admin API operation latency, including retries.",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     opts.Buckets,
+		}, labels),
+	}
+}
+
+// ObserveCall - implements radosgwadmin.MetricsObserver
+func (c *Collector) ObserveCall(obs radosgwadmin.CallObservation) {
+	c.requests.WithLabelValues(obs.Operation, obs.Method).Inc()
+	c.duration.WithLabelValues(obs.Operation, obs.Method).Observe(obs.Duration.Seconds())
+	if obs.ErrorCode != "" {
+		c.errors.WithLabelValues(obs.Operation, obs.Method, obs.ErrorCode).Inc()
+	}
+}
+
+// Describe - implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.errors.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+// Collect - implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.errors.Collect(ch)
+	c.duration.Collect(ch)
+}
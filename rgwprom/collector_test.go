@@ -0,0 +1,92 @@
+package rgwprom
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/myENA/radosgwadmin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/suite"
+)
+
+type CollectorSuite struct {
+	suite.Suite
+	c   *Collector
+	aa  *radosgwadmin.AdminAPI
+	srv *httptest.Server
+}
+
+func (cs *CollectorSuite) SetupTest() {
+	cs.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("uid") == "nobody":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"Code":"NoSuchUser"}`)
+		case r.URL.Path == "/admin/bucket" && r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `<html>bad gateway</html>`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	cs.c = NewCollector(Opts{ConstLabels: prometheus.Labels{"cluster": "test"}})
+	var err error
+	cs.aa, err = radosgwadmin.NewAdminAPI(&radosgwadmin.Config{
+		ServerURL:       cs.srv.URL,
+		AdminPath:       "admin",
+		AccessKeyID:     "a",
+		SecretAccessKey: "b",
+		Metrics:         cs.c,
+		Retry:           radosgwadmin.RetryPolicy{MaxAttempts: 1},
+	})
+	cs.Require().NoError(err)
+}
+
+func (cs *CollectorSuite) TearDownTest() {
+	cs.srv.Close()
+}
+
+func (cs *CollectorSuite) TestCollect() {
+	ctx := context.Background()
+	_, err := cs.aa.MListUsers(ctx)
+	cs.NoError(err)
+	_, err = cs.aa.MListUsers(ctx)
+	cs.NoError(err)
+	_, err = cs.aa.UserInfo(ctx, radosgwadmin.UserID{ID: "nobody"}, false)
+	cs.Error(err)
+	cs.Error(cs.aa.BucketRm(ctx, radosgwadmin.BucketRef{Name: "b1"}, false))
+
+	reg := prometheus.NewPedanticRegistry()
+	cs.Require().NoError(reg.Register(cs.c))
+
+	expected := `
+# HELP radosgwadmin_requests_total Admin API operations performed.
+# TYPE radosgwadmin_requests_total counter
+radosgwadmin_requests_total{cluster="test",method="DELETE",operation="BucketRm"} 1
+radosgwadmin_requests_total{cluster="test",method="GET",operation="MListUsers"} 2
+radosgwadmin_requests_total{cluster="test",method="GET",operation="UserInfo"} 1
+# HELP radosgwadmin_request_errors_total Admin API operations that failed, by RGW error code.
+# TYPE radosgwadmin_request_errors_total counter
+radosgwadmin_request_errors_total{cluster="test",code="NoSuchUser",method="GET",operation="UserInfo"} 1
+radosgwadmin_request_errors_total{cluster="test",code="Unknown",method="DELETE",operation="BucketRm"} 1
+`
+	cs.NoError(testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"radosgwadmin_requests_total", "radosgwadmin_request_errors_total"))
+	cs.Equal(3, testutil.CollectAndCount(cs.c, "radosgwadmin_request_duration_seconds"))
+}
+
+func (cs *CollectorSuite) TestClientError() {
+	cs.srv.Close()
+	_, err := cs.aa.MListUsers(context.Background())
+	cs.Error(err)
+	cs.Equal(1.0, testutil.ToFloat64(cs.c.errors.WithLabelValues("MListUsers", "GET", radosgwadmin.ErrorCodeClient)))
+}
+
+func TestCollector(t *testing.T) {
+	suite.Run(t, new(CollectorSuite))
+}
@@ -0,0 +1,310 @@
+package radosgwadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RotationState - how far a key rotation got.
+type RotationState string
+
+// Key rotation states, in the order they are reached.
+const (
+	RotationStarted   RotationState = "started"
+	RotationCreated   RotationState = "created"  // the new key exists
+	RotationVerified  RotationState = "verified" // the new key authenticates
+	RotationDelivered RotationState = "delivered"
+	RotationCompleted RotationState = "completed" // the old key is gone
+
+	// RotationRolledBack - the new key was removed, the old one kept.
+	RotationRolledBack RotationState = "rolled-back"
+)
+
+// ErrRotationRolledBack - returned when resuming a rotation that was rolled back.
+var ErrRotationRolledBack = errors.New("radosgwadmin: key rotation was rolled back")
+
+// RotationStep - a state a key rotation reached, and when.
+type RotationStep struct {
+	State RotationState `json:"state"`
+	Time  time.Time     `json:"time"`
+}
+
+// KeyRotation - the record of a key rotation, see RotateKey.  It holds no
+// secrets, so it can be stored as is, e.g. as json, to resume or roll back a
+// rotation later.
+type KeyRotation struct {
+	UID          UserID         `json:"uid"`
+	OldAccessKey string         `json:"old_access_key"`
+	NewAccessKey string         `json:"new_access_key,omitempty"`
+	State        RotationState  `json:"state"`
+	Steps        []RotationStep `json:"steps"`
+	// ExistingKeys - the user's access keys before the rotation, to tell which is
+	// the new one if the rotation was interrupted right after creating it.
+	ExistingKeys []string `json:"existing_keys"`
+}
+
+// RotateKeyOptions - options for RotateKey.
+type RotateKeyOptions struct {
+	// Verify - check that the new key authenticates, with a signed S3 request
+	// to the gateway, before delivering it.
+	Verify bool
+	// Deliver - hand the new key over, e.g. to a secret store.  Required.
+	Deliver func(ctx context.Context, key UserKey) error
+	// GracePeriod - how long after delivery to keep the old key, for clients to
+	// switch over.  RotateKey waits for it, unless ctx is done first.
+	GracePeriod time.Duration
+	// Confirm - if set, asked after the grace period whether the old key can
+	// go.  If it says no, the rotation stops at RotationDelivered.
+	//
+	// The old key is only removed once the grace period is over and Confirm, if
+	// set, agrees.  With neither a grace period nor Confirm the rotation always
+	// stops at RotationDelivered, to be resumed by ResumeKeyRotation.
+	Confirm func(ctx context.Context, rot *KeyRotation) (bool, error)
+	// Record - called with the rotation after each step, to persist it.  An
+	// error stops the rotation.
+	Record func(rot *KeyRotation) error
+}
+
+// RotateKey - replace the user's s3 key oldAccessKey with a new one.  The new key
+// is created, optionally verified, delivered with opts.Deliver, and the old key
+// removed when opts allow it.  The rotation is returned in every case, and if it
+// did not complete it can be resumed with ResumeKeyRotation or undone with
+// RollbackKeyRotation.
+func (aa *AdminAPI) RotateKey(ctx context.Context, uid UserID, oldAccessKey string, opts RotateKeyOptions) (*KeyRotation, error) {
+	if opts.Deliver == nil {
+		return nil, errors.New("radosgwadmin: RotateKeyOptions.Deliver is required")
+	}
+	ui, err := aa.UserInfo(ctx, uid, false)
+	if err != nil {
+		return nil, err
+	}
+	rot := &KeyRotation{UID: uid, OldAccessKey: oldAccessKey, ExistingKeys: []string{}}
+	found := false
+	for _, k := range ui.Keys {
+		rot.ExistingKeys = append(rot.ExistingKeys, k.AccessKey)
+		found = found || (k.AccessKey == oldAccessKey && k.User == uid.String())
+	}
+	if !found {
+		return nil, fmt.Errorf("radosgwadmin: %s has no s3 key %s", uid, oldAccessKey)
+	}
+	if err = rot.advance(RotationStarted, opts); err != nil {
+		return rot, err
+	}
+	return rot, aa.ResumeKeyRotation(ctx, rot, opts)
+}
+
+// ResumeKeyRotation - carry on with a rotation from where it stopped, e.g. after
+// an error, or to remove the old key once it is no longer used.
+func (aa *AdminAPI) ResumeKeyRotation(ctx context.Context, rot *KeyRotation, opts RotateKeyOptions) error {
+	if opts.Deliver == nil && rot.State != RotationDelivered {
+		return errors.New("radosgwadmin: RotateKeyOptions.Deliver is required")
+	}
+	switch rot.State {
+	case RotationStarted:
+		if err := aa.rotationCreate(ctx, rot); err != nil {
+			return err
+		}
+		if err := rot.advance(RotationCreated, opts); err != nil {
+			return err
+		}
+		fallthrough
+	case RotationCreated:
+		if opts.Verify {
+			key, err := aa.rotationKey(ctx, rot)
+			if err != nil {
+				return err
+			}
+			if err = aa.verifyKey(ctx, key); err != nil {
+				return err
+			}
+			if err = rot.advance(RotationVerified, opts); err != nil {
+				return err
+			}
+		}
+		fallthrough
+	case RotationVerified:
+		key, err := aa.rotationKey(ctx, rot)
+		if err != nil {
+			return err
+		}
+		if err = opts.Deliver(ctx, key); err != nil {
+			return fmt.Errorf("radosgwadmin: delivering key %s: %w", key.AccessKey, err)
+		}
+		if err = rot.advance(RotationDelivered, opts); err != nil {
+			return err
+		}
+		fallthrough
+	case RotationDelivered:
+		if ok, err := rot.oldKeyDue(ctx, opts); !ok {
+			return err
+		}
+		if err := aa.rotationRemove(ctx, rot, rot.OldAccessKey); err != nil {
+			return err
+		}
+		return rot.advance(RotationCompleted, opts)
+	case RotationCompleted:
+		return nil
+	case RotationRolledBack:
+		return ErrRotationRolledBack
+	}
+	return fmt.Errorf("radosgwadmin: unknown key rotation state %q", rot.State)
+}
+
+// RollbackKeyRotation - remove the new key of a rotation that has not completed,
+// leaving the user with the old one.  Only opts.Record is used.
+func (aa *AdminAPI) RollbackKeyRotation(ctx context.Context, rot *KeyRotation, opts RotateKeyOptions) error {
+	switch rot.State {
+	case RotationRolledBack:
+		return nil
+	case RotationCompleted:
+		return errors.New("radosgwadmin: key rotation completed, the old key is gone")
+	case RotationStarted:
+		// The new key may or may not have been created.
+		if err := aa.rotationFind(ctx, rot); err != nil {
+			return err
+		}
+	}
+	if rot.NewAccessKey != "" {
+		if err := aa.rotationRemove(ctx, rot, rot.NewAccessKey); err != nil {
+			return err
+		}
+	}
+	return rot.advance(RotationRolledBack, opts)
+}
+
+// advance - record that rot reached state.
+func (rot *KeyRotation) advance(state RotationState, opts RotateKeyOptions) error {
+	rot.State = state
+	rot.Steps = append(rot.Steps, RotationStep{State: state, Time: timeNow().UTC()})
+	if opts.Record == nil {
+		return nil
+	}
+	return opts.Record(rot)
+}
+
+// reached - when rot reached state, zero if it didn't.
+func (rot *KeyRotation) reached(state RotationState) time.Time {
+	for _, s := range rot.Steps {
+		if s.State == state {
+			return s.Time
+		}
+	}
+	return time.Time{}
+}
+
+// oldKeyDue - wait out the grace period, and check with opts.Confirm.
+func (rot *KeyRotation) oldKeyDue(ctx context.Context, opts RotateKeyOptions) (bool, error) {
+	if opts.GracePeriod <= 0 && opts.Confirm == nil {
+		return false, nil
+	}
+	if wait := rot.reached(RotationDelivered).Add(opts.GracePeriod).Sub(timeNow()); wait > 0 {
+		if !sleepCtx(ctx, wait) {
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			return false, context.DeadlineExceeded
+		}
+	}
+	if opts.Confirm == nil {
+		return true, nil
+	}
+	return opts.Confirm(ctx, rot)
+}
+
+// rotationFind - look for a key the user didn't have before the rotation.
+func (aa *AdminAPI) rotationFind(ctx context.Context, rot *KeyRotation) error {
+	ui, err := aa.UserInfo(ctx, rot.UID, false)
+	if err != nil {
+		return err
+	}
+	rot.NewAccessKey = newKey(ui.Keys, rot)
+	return nil
+}
+
+// rotationCreate - create the new key, unless an interrupted attempt did.
+func (aa *AdminAPI) rotationCreate(ctx context.Context, rot *KeyRotation) error {
+	if err := aa.rotationFind(ctx, rot); err != nil || rot.NewAccessKey != "" {
+		return err
+	}
+	keys, err := aa.KeyCreate(ctx, &KeyCreateRequest{UID: rot.UID, KeyType: "s3"})
+	if err != nil {
+		return err
+	}
+	if rot.NewAccessKey = newKey(keys, rot); rot.NewAccessKey == "" {
+		return fmt.Errorf("radosgwadmin: KeyCreate for %s returned no new key", rot.UID)
+	}
+	return nil
+}
+
+func newKey(keys []UserKey, rot *KeyRotation) string {
+	existing := map[string]bool{rot.OldAccessKey: true}
+	for _, k := range rot.ExistingKeys {
+		existing[k] = true
+	}
+	for _, k := range keys {
+		if k.User == rot.UID.String() && !existing[k.AccessKey] {
+			return k.AccessKey
+		}
+	}
+	return ""
+}
+
+// rotationKey - the new key, with its secret.
+func (aa *AdminAPI) rotationKey(ctx context.Context, rot *KeyRotation) (UserKey, error) {
+	ui, err := aa.UserInfo(ctx, rot.UID, false)
+	if err != nil {
+		return UserKey{}, err
+	}
+	for _, k := range ui.Keys {
+		if k.AccessKey == rot.NewAccessKey {
+			return k, nil
+		}
+	}
+	return UserKey{}, fmt.Errorf("radosgwadmin: %s no longer has the new key %s", rot.UID, rot.NewAccessKey)
+}
+
+// rotationRemove - remove one of the user's keys, if it is still there.
+func (aa *AdminAPI) rotationRemove(ctx context.Context, rot *KeyRotation, accessKey string) error {
+	ui, err := aa.UserInfo(ctx, rot.UID, false)
+	if err != nil {
+		return err
+	}
+	for _, k := range ui.Keys {
+		if k.AccessKey == accessKey {
+			return aa.KeyRm(ctx, &KeyRmRequest{UID: rot.UID, AccessKey: accessKey, KeyType: "s3"})
+		}
+	}
+	return nil
+}
+
+// verifyKey - check that key authenticates, by listing its buckets with a
+// request to the S3 api of the primary gateway.  The request doesn't go through
+// the middleware.
+func (aa *AdminAPI) verifyKey(ctx context.Context, key UserKey) error {
+	u := *aa.endpoints.primaryServer()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	signer := *aa
+	signer.creds = StaticCredentials{AccessKeyID: key.AccessKey, SecretAccessKey: key.SecretKey}
+	if err = signer.sign(req); err != nil {
+		return err
+	}
+	var rt http.RoundTripper = http.DefaultTransport
+	if at, ok := aa.Client.Client.Transport.(*adminTransport); ok && at.base != nil {
+		rt = at.base
+	}
+	resp, err := rt.RoundTrip(req)
+	if err == nil {
+		resp, err = checkStatus(resp)
+		discard(resp)
+	}
+	if err != nil {
+		return fmt.Errorf("radosgwadmin: verifying key %s: %w", key.AccessKey, err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package radosgwadmin_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	rgw "github.com/myENA/radosgwadmin"
+	"github.com/stretchr/testify/suite"
+)
+
+type RotateSuite struct {
+	fakeSuite
+	uid       rgw.UserID
+	oldKey    string
+	delivered []rgw.UserKey
+	states    []rgw.RotationState
+	saved     []byte // the last recorded rotation
+}
+
+func (rs *RotateSuite) SetupTest() {
+	rs.fakeSuite.SetupTest()
+	rs.uid = rgw.UserID{ID: "dave"}
+	ui, err := rs.aa.UserCreate(rs.ctx, &rgw.UserCreateRequest{UID: rgw.UserID{ID: "dave"}, DisplayName: "Dave"})
+	rs.Require().NoError(err)
+	rs.oldKey = ui.Keys[0].AccessKey
+	rs.delivered, rs.states, rs.saved = nil, nil, nil
+}
+
+func (rs *RotateSuite) opts() rgw.RotateKeyOptions {
+	return rgw.RotateKeyOptions{
+		Verify: true,
+		Deliver: func(ctx context.Context, key rgw.UserKey) error {
+			rs.delivered = append(rs.delivered, key)
+			return nil
+		},
+		Record: func(rot *rgw.KeyRotation) error {
+			rs.states = append(rs.states, rot.State)
+			var err error
+			rs.saved, err = json.Marshal(rot)
+			return err
+		},
+	}
+}
+
+func (rs *RotateSuite) accessKeys() []string {
+	ui, err := rs.aa.UserInfo(rs.ctx, rs.uid, false)
+	rs.Require().NoError(err)
+	var keys []string
+	for _, k := range ui.Keys {
+		keys = append(keys, k.AccessKey)
+	}
+	return keys
+}
+
+func (rs *RotateSuite) TestRotate() {
+	opts := rs.opts()
+	opts.GracePeriod = 20 * time.Millisecond
+	start := time.Now()
+	rot, err := rs.aa.RotateKey(rs.ctx, rs.uid, rs.oldKey, opts)
+	rs.Require().NoError(err)
+	rs.GreaterOrEqual(int64(time.Since(start)), int64(opts.GracePeriod))
+	rs.Equal(rgw.RotationCompleted, rot.State)
+	rs.Equal([]rgw.RotationState{
+		rgw.RotationStarted, rgw.RotationCreated, rgw.RotationVerified, rgw.RotationDelivered, rgw.RotationCompleted,
+	}, rs.states)
+	rs.Require().Len(rs.delivered, 1)
+	rs.Equal(rot.NewAccessKey, rs.delivered[0].AccessKey)
+	rs.NotEmpty(rs.delivered[0].SecretKey)
+	rs.Equal([]string{rot.NewAccessKey}, rs.accessKeys())
+	rs.NotContains(string(rs.saved), rs.delivered[0].SecretKey, "records must not hold secrets")
+
+	_, err = rs.aa.RotateKey(rs.ctx, rs.uid, rs.oldKey, opts)
+	rs.Error(err, "the old key is gone")
+}
+
+func (rs *RotateSuite) TestConfirm() {
+	rot, err := rs.aa.RotateKey(rs.ctx, rs.uid, rs.oldKey, rs.opts())
+	rs.Require().NoError(err)
+	rs.Equal(rgw.RotationDelivered, rot.State, "without a grace period or confirmation the old key stays")
+	rs.ElementsMatch([]string{rs.oldKey, rot.NewAccessKey}, rs.accessKeys())
+
+	opts := rs.opts()
+	opts.Deliver = nil
+	opts.Confirm = func(ctx context.Context, rot *rgw.KeyRotation) (bool, error) {
+		return false, nil
+	}
+	rs.NoError(rs.aa.ResumeKeyRotation(rs.ctx, rot, opts))
+	rs.Equal(rgw.RotationDelivered, rot.State)
+
+	opts.Confirm = func(ctx context.Context, rot *rgw.KeyRotation) (bool, error) {
+		return true, nil
+	}
+	rs.NoError(rs.aa.ResumeKeyRotation(rs.ctx, rot, opts))
+	rs.Equal(rgw.RotationCompleted, rot.State)
+	rs.Equal([]string{rot.NewAccessKey}, rs.accessKeys())
+	rs.Len(rs.delivered, 1)
+}
+
+func (rs *RotateSuite) TestRollback() {
+	opts := rs.opts()
+	opts.Deliver = func(ctx context.Context, key rgw.UserKey) error {
+		return errors.New("vault is sealed")
+	}
+	rot, err := rs.aa.RotateKey(rs.ctx, rs.uid, rs.oldKey, opts)
+	rs.Require().Error(err)
+	rs.Contains(err.Error(), "vault is sealed")
+	rs.Equal(rgw.RotationVerified, rot.State)
+	rs.Len(rs.accessKeys(), 2)
+
+	rs.Require().NoError(rs.aa.RollbackKeyRotation(rs.ctx, rot, opts))
+	rs.Equal(rgw.RotationRolledBack, rot.State)
+	rs.Equal([]string{rs.oldKey}, rs.accessKeys())
+	err = rs.aa.ResumeKeyRotation(rs.ctx, rot, rs.opts())
+	rs.True(errors.Is(err, rgw.ErrRotationRolledBack), "got %v", err)
+}
+
+func (rs *RotateSuite) TestVerify() {
+	_, err := rs.aa.UserModify(rs.ctx, &rgw.UserModifyRequest{UID: rgw.UserID{ID: "dave"}, Suspended: rgw.TrueRef})
+	rs.Require().NoError(err)
+	rot, err := rs.aa.RotateKey(rs.ctx, rs.uid, rs.oldKey, rs.opts())
+	rs.True(errors.Is(err, rgw.ErrUserSuspended), "got %v", err)
+	rs.Equal(rgw.RotationCreated, rot.State)
+	rs.Empty(rs.delivered, "keys that don't work are not delivered")
+}
+
+func (rs *RotateSuite) TestAdminPathRoot() {
+	// The key is checked against the S3 api at the server url, wherever the
+	// admin api is.
+	rs.srv.AdminPath = ""
+	aa, err := rgw.NewAdminAPI(rs.srv.Config())
+	rs.Require().NoError(err)
+	rot, err := aa.RotateKey(rs.ctx, rs.uid, rs.oldKey, rs.opts())
+	rs.Require().NoError(err)
+	rs.Equal(rgw.RotationDelivered, rot.State)
+	rs.Len(rs.delivered, 1)
+}
+
+func (rs *RotateSuite) TestResume() {
+	// Fail to record the new key, as if the process died right after creating
+	// it.  The saved rotation is still at started.
+	opts := rs.opts()
+	record := opts.Record
+	opts.Record = func(rot *rgw.KeyRotation) error {
+		if rot.State == rgw.RotationCreated {
+			return errors.New("disk full")
+		}
+		return record(rot)
+	}
+	_, err := rs.aa.RotateKey(rs.ctx, rs.uid, rs.oldKey, opts)
+	rs.Require().Error(err)
+	rs.Len(rs.accessKeys(), 2)
+
+	rot := &rgw.KeyRotation{}
+	rs.Require().NoError(json.Unmarshal(rs.saved, rot))
+	rs.Equal(rgw.RotationStarted, rot.State)
+	opts = rs.opts()
+	opts.GracePeriod = time.Nanosecond
+	rs.Require().NoError(rs.aa.ResumeKeyRotation(rs.ctx, rot, opts))
+	rs.Equal(rgw.RotationCompleted, rot.State)
+	rs.Equal([]string{rot.NewAccessKey}, rs.accessKeys(), "the key created before is picked up, not a second one")
+}
+
+func TestRotate(t *testing.T) {
+	suite.Run(t, new(RotateSuite))
+}
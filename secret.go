@@ -0,0 +1,185 @@
+package radosgwadmin
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Secret - key material, such as a secret key, that keeps out of logs.  It is
+// printed, with any fmt verb, and marshaled to json as Redacted, so dumping a
+// UserInfoResponse or a Config is safe.  Reveal returns the actual value, and
+// SecretEncoder writes json with secrets included, for the few places that have
+// to store them.
+//
+// The empty secret stays empty, a missing secret is worth seeing.  Secrets are
+// decoded from json, toml etc. like any string.
+type Secret string
+
+// Reveal - the secret itself.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// String - implements fmt.Stringer
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// GoString - implements fmt.GoStringer
+func (s Secret) GoString() string {
+	return strconv.Quote(s.String())
+}
+
+// Format - implements fmt.Formatter, so that no verb shows the secret.  Flags,
+// width and precision apply to the redacted value.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = io.WriteString(f, s.GoString())
+		return
+	}
+	directive := "%"
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			directive += string(flag)
+		}
+	}
+	if w, ok := f.Width(); ok {
+		directive += strconv.Itoa(w)
+	}
+	if p, ok := f.Precision(); ok {
+		directive += "." + strconv.Itoa(p)
+	}
+	fmt.Fprintf(f, directive+string(verb), s.String())
+}
+
+// MarshalJSON - implements json.Marshaler
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if strings.HasPrefix(string(s), revealPrefix) {
+		// A placeholder put in by SecretEncoder.
+		return json.Marshal(string(s))
+	}
+	return json.Marshal(s.String())
+}
+
+// revealPrefix - starts the placeholders SecretEncoder replaces secrets with
+// while marshaling.  The random part keeps secrets from passing for one.
+var revealPrefix = func() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return "radosgwadmin-secret-" + hex.EncodeToString(b) + "-"
+}()
+
+// SecretEncoder - like json.Encoder, but secrets are written as they are rather
+// than redacted.  Use it only where secrets have to be stored, e.g. the report
+// written by Provision.
+type SecretEncoder struct {
+	w io.Writer
+}
+
+// NewSecretEncoder - a SecretEncoder writing to w.
+func NewSecretEncoder(w io.Writer) *SecretEncoder {
+	return &SecretEncoder{w: w}
+}
+
+// Encode - write the json encoding of v, revealing every Secret in it, followed by
+// a newline.  v is not modified.  Secrets in unexported fields stay redacted.
+func (se *SecretEncoder) Encode(v interface{}) error {
+	b, err := MarshalSecrets(v)
+	if err != nil {
+		return err
+	}
+	_, err = se.w.Write(append(b, '\n'))
+	return err
+}
+
+// MarshalSecrets - json.Marshal, revealing every Secret in v, see SecretEncoder.
+func MarshalSecrets(v interface{}) ([]byte, error) {
+	var secrets []Secret
+	if rv := reflect.ValueOf(v); rv.IsValid() {
+		v = withPlaceholders(rv, &secrets).Interface()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range secrets {
+		placeholder, _ := json.Marshal(revealPrefix + strconv.Itoa(i))
+		revealed, err := json.Marshal(s.Reveal())
+		if err != nil {
+			return nil, err
+		}
+		b = bytes.Replace(b, placeholder, revealed, 1)
+	}
+	return b, nil
+}
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// withPlaceholders - a deep copy of v with every non-empty Secret replaced by a
+// placeholder, the index in secrets of the original.
+func withPlaceholders(v reflect.Value, secrets *[]Secret) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Type() == secretType && v.Len() > 0 {
+			*secrets = append(*secrets, Secret(v.String()))
+			return reflect.ValueOf(Secret(revealPrefix + strconv.Itoa(len(*secrets)-1)))
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			c := reflect.New(v.Type().Elem())
+			c.Elem().Set(withPlaceholders(v.Elem(), secrets))
+			return c
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			c := reflect.New(v.Type()).Elem()
+			c.Set(withPlaceholders(v.Elem(), secrets))
+			return c
+		}
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(withPlaceholders(v.Field(i), secrets))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if !v.IsNil() {
+			c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			for i := 0; i < v.Len(); i++ {
+				c.Index(i).Set(withPlaceholders(v.Index(i), secrets))
+			}
+			return c
+		}
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(withPlaceholders(v.Index(i), secrets))
+		}
+		return c
+	case reflect.Map:
+		if !v.IsNil() {
+			c := reflect.MakeMapWithSize(v.Type(), v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				c.SetMapIndex(iter.Key(), withPlaceholders(iter.Value(), secrets))
+			}
+			return c
+		}
+	}
+	return v
+}
@@ -0,0 +1,105 @@
+package radosgwadmin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/davecgh/go-spew/spew"
+	"github.com/stretchr/testify/suite"
+)
+
+const testSecret = "h7GhxuBLTrlhVUyxSPUKUV8r"
+
+type SecretSuite struct {
+	suite.Suite
+	user *UserInfoResponse
+}
+
+func (ss *SecretSuite) SetupTest() {
+	ss.user = &UserInfoResponse{
+		UserID:      "alice",
+		Keys:        []UserKey{{User: "alice", AccessKey: "AK", SecretKey: testSecret}},
+		SwiftKeys:   []SwiftKey{{User: "alice:swift", SecretKey: testSecret + "swift"}},
+		TempURLKeys: []TempURLKey{{Key: 0, Val: testSecret + "tempurl"}},
+	}
+}
+
+func (ss *SecretSuite) TestFormat() {
+	s := Secret(testSecret)
+	ss.Equal(testSecret, s.Reveal())
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%10s", "%.3s", "%d"} {
+		out := fmt.Sprintf(verb, s)
+		ss.NotContains(out, testSecret, verb)
+		ss.NotContains(out, fmt.Sprintf("%x", testSecret), verb)
+	}
+	ss.Equal(Redacted, fmt.Sprint(s))
+	ss.Equal(`"REDACTED"`, fmt.Sprintf("%#v", s))
+	ss.Equal(`  REDACTED`, fmt.Sprintf("%10s", s))
+	ss.Equal("", Secret("").String(), "a missing secret is shown as such")
+
+	for _, verb := range []string{"%v", "%+v", "%#v"} {
+		ss.NotContains(fmt.Sprintf(verb, ss.user), testSecret, verb)
+		ss.NotContains(fmt.Sprintf(verb, *ss.user), testSecret, verb)
+	}
+	ss.NotContains(spew.Sdump(ss.user), testSecret)
+
+	cfg := &Config{ServerURL: "http://rgw", AccessKeyID: "AK", SecretAccessKey: testSecret, SecurityToken: testSecret}
+	ss.NotContains(fmt.Sprintf("%+v", cfg), testSecret)
+	ss.NotContains(fmt.Sprintf("%#v", Credentials{SecretAccessKey: testSecret}), testSecret)
+}
+
+func (ss *SecretSuite) TestJSON() {
+	b, err := json.Marshal(ss.user)
+	ss.Require().NoError(err)
+	ss.NotContains(string(b), testSecret)
+	ss.Contains(string(b), `"secret_key":"REDACTED"`)
+
+	b, err = json.Marshal(Credentials{AccessKeyID: "AK", SecretAccessKey: testSecret})
+	ss.Require().NoError(err)
+	ss.NotContains(string(b), testSecret)
+	ss.Contains(string(b), `"SecurityToken":""`)
+
+	// Secrets are read like any string.
+	cfg := &Config{}
+	ss.Require().NoError(json.Unmarshal([]byte(`{"SecretAccessKey":"`+testSecret+`"}`), cfg))
+	ss.Equal(testSecret, cfg.SecretAccessKey.Reveal())
+	cfg = &Config{}
+	_, err = toml.Decode(`SecretAccessKey = "`+testSecret+`"`, cfg)
+	ss.Require().NoError(err)
+	ss.Equal(testSecret, cfg.SecretAccessKey.Reveal())
+}
+
+func (ss *SecretSuite) TestEncoder() {
+	var buf bytes.Buffer
+	enc := NewSecretEncoder(&buf)
+	ss.Require().NoError(enc.Encode(ss.user))
+	ss.Require().NoError(enc.Encode(map[string]interface{}{"keys": ss.user.Keys, "none": Secret("")}))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	ss.Require().Len(lines, 2)
+
+	decoded := &UserInfoResponse{}
+	ss.Require().NoError(json.Unmarshal([]byte(lines[0]), decoded))
+	ss.Equal(ss.user.Keys, decoded.Keys)
+	ss.Equal(ss.user.SwiftKeys, decoded.SwiftKeys)
+	ss.Equal(ss.user.TempURLKeys, decoded.TempURLKeys)
+	ss.Equal(`{"keys":[{"user":"alice","access_key":"AK","secret_key":"`+testSecret+`"}],"none":""}`, lines[1])
+
+	// The value encoded is left alone.
+	ss.Equal(Secret(testSecret), ss.user.Keys[0].SecretKey)
+	b, err := MarshalSecrets(nil)
+	ss.Require().NoError(err)
+	ss.Equal("null", string(b))
+
+	// A value that looks like a placeholder is still redacted.
+	b, err = json.Marshal(Secret("radosgwadmin-secret-0"))
+	ss.Require().NoError(err)
+	ss.Equal(`"REDACTED"`, string(b))
+}
+
+func TestSecret(t *testing.T) {
+	suite.Run(t, new(SecretSuite))
+}
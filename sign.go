@@ -0,0 +1,274 @@
+package radosgwadmin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smartystreets/go-aws-auth"
+)
+
+// SigningMode - selects the AWS signature algorithm used to sign admin requests.
+type SigningMode string
+
+const (
+	// SigningModeV2 - AWS signature version 2, S3 flavor.  This is the default.
+	SigningModeV2 SigningMode = "v2"
+
+	// SigningModeV4 - AWS signature version 4.  Use this for gateways that have
+	// rgw_s3_auth_use_sigv4 enforced.
+	SigningModeV4 SigningMode = "v4"
+)
+
+// DefaultSigningRegion - region used for V4 signatures if none is configured.
+// radosgw accepts the zonegroup api name here, which is us-east-1 unless
+// configured otherwise.
+const DefaultSigningRegion = "us-east-1"
+
+// DefaultSigningService - service used for V4 signatures if none is configured.
+const DefaultSigningService = "s3"
+
+const (
+	v4Algorithm       = "AWS4-HMAC-SHA256"
+	v4TimeFormat      = "20060102T150405Z"
+	v4DateFormat      = "20060102"
+	v4EmptyPayloadSum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// v4Signer - signs requests with AWS signature version 4 for a fixed
+// region and service.
+type v4Signer struct {
+	region  string
+	service string
+	now     func() time.Time
+}
+
+func newV4Signer(region, service string) *v4Signer {
+	if region == "" {
+		region = DefaultSigningRegion
+	}
+	if service == "" {
+		service = DefaultSigningService
+	}
+	return &v4Signer{
+		region:  region,
+		service: service,
+		now:     time.Now,
+	}
+}
+
+// sign - sign req in place, setting the X-Amz-Date, X-Amz-Content-Sha256,
+// and Authorization headers.  The host, content-type, content-md5 and all
+// x-amz-* headers are signed.
+func (s *v4Signer) sign(req *http.Request, creds awsauth.Credentials) error {
+	t := s.now().UTC()
+	amzDate := t.Format(v4TimeFormat)
+
+	payloadSum, err := payloadSHA256(req)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadSum)
+	if creds.SecurityToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SecurityToken)
+	}
+
+	signedHeaders, canonHeaders := v4CanonicalHeaders(req)
+
+	canonReq := strings.Join([]string{
+		req.Method,
+		v4CanonicalURI(req.URL),
+		v4CanonicalQuery(req.URL),
+		canonHeaders,
+		signedHeaders,
+		payloadSum,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format(v4DateFormat), s.region, s.service, "aws4_request"}, "/")
+
+	stringToSign := strings.Join([]string{
+		v4Algorithm,
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonReq)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), t.Format(v4DateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		v4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// payloadSHA256 - hex encoded sha256 of the request body.
+func payloadSHA256(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return v4EmptyPayloadSum, nil
+	}
+	h := sha256.New()
+	if err := hashBody(req, h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// setContentMD5 - set the Content-Md5 header of a request with a body, if it
+// has none.  The gateway only includes the header in V2 signatures, not the
+// body itself.
+func setContentMD5(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Md5") != "" {
+		return nil
+	}
+	h := md5.New()
+	if err := hashBody(req, h); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	return nil
+}
+
+// hashBody - write the request body to h.  The body is read through GetBody
+// where possible so that it is left intact for sending.
+func hashBody(req *http.Request, h hash.Hash) error {
+	if req.GetBody == nil {
+		// No way to rewind, so buffer the body and put it back.
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		_ = req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		_, _ = h.Write(b)
+		return nil
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(h, rc)
+	return err
+}
+
+func v4CanonicalHeaders(req *http.Request) (signed string, canonical string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	vals := map[string]string{"host": host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" || lk == "content-md5" {
+			trimmed := make([]string, len(v))
+			for i := range v {
+				trimmed[i] = strings.Join(strings.Fields(v[i]), " ")
+			}
+			vals[lk] = strings.Join(trimmed, ",")
+		}
+	}
+	names := make([]string, 0, len(vals))
+	for k := range vals {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, n := range names {
+		b.WriteString(n)
+		b.WriteByte(':')
+		b.WriteString(vals[n])
+		b.WriteByte('\n')
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+func v4CanonicalURI(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	// Re-encode each segment so that the result is independent of how the
+	// caller escaped the path.
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		if unescaped, err := url.PathUnescape(seg); err == nil {
+			seg = unescaped
+		}
+		segs[i] = v4Escape(seg)
+	}
+	return strings.Join(segs, "/")
+}
+
+func v4CanonicalQuery(u *url.URL) string {
+	q := u.Query()
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	// sort on the encoded key, then on the encoded value.
+	sort.Slice(keys, func(i, j int) bool { return v4Escape(keys[i]) < v4Escape(keys[j]) })
+	pairs := make([]string, 0, len(q))
+	for _, k := range keys {
+		vs := make([]string, len(q[k]))
+		for i, v := range q[k] {
+			vs[i] = v4Escape(v)
+		}
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, v4Escape(k)+"="+v)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// v4Escape - uri encode per the sigv4 rules: everything but unreserved
+// characters is percent encoded, with upper case hex.
+func v4Escape(s string) string {
+	const hexChars = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexChars[c>>4])
+		b.WriteByte(hexChars[c&0x0f])
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -46,12 +46,14 @@ func main() {
 		log.Fatalf("Could not parse URL: %s", cfg.RGW.ServerURL)
 	}
 
-	aa, err := rgw.NewAdminAPI(cfg.RGW)
+	// The AdminAPI transport signs requests itself, so build a plain client
+	// from the same config and do the signing here.
+	c, err := restclient.NewClient(&cfg.RGW.ClientConfig, nil)
 	if err != nil {
-		log.Fatalf("Could not initialize admin api: %s", err)
+		log.Fatalf("Could not initialize http client: %s", err)
 	}
 
-	p := newProxy(target, cfg, aa.Client.Client.Transport)
+	p := newProxy(target, cfg, c.Client.Transport)
 	http.HandleFunc("/", p.proxy.ServeHTTP)
 	http.ListenAndServe(fmt.Sprintf("%s:%d", cfg.Server.ServiceHost, cfg.Server.ServicePort), nil)
 
@@ -70,8 +72,8 @@ func newProxy(target *url.URL, cfg *config, transport http.RoundTripper) *proxy
 	p.cfg = cfg
 	p.creds = awsauth.Credentials{
 		AccessKeyID:     cfg.RGW.AccessKeyID,
-		SecretAccessKey: cfg.RGW.SecretAccessKey,
-		SecurityToken:   cfg.RGW.SecurityToken,
+		SecretAccessKey: cfg.RGW.SecretAccessKey.Reveal(),
+		SecurityToken:   cfg.RGW.SecurityToken.Reveal(),
 		Expiration:      cfg.RGW.Expiration,
 	}
 	p.proxy = new(httputil.ReverseProxy)
@@ -0,0 +1,123 @@
+package radosgwadmin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Tracer - adapter between AdminAPI and a tracing library, so that the package
+// does not depend on any particular SDK.  AdminAPI starts one span per
+// operation, named after the method, e.g. "radosgwadmin.UserCreate", as a child
+// of whatever span is in the context passed to the method.  The context returned
+// by Start is the one used for the http requests, so middleware sees it too.
+//
+// If the Tracer also implements TraceInjector, trace context is injected into the
+// headers of every request sent to the gateway.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span - the part of a span that AdminAPI uses.  Attribute values are strings or
+// ints.  Secret values, such as secret keys, are never recorded.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// TraceInjector - optionally implemented by a Tracer to propagate trace context
+// to the gateway, for example as a traceparent header.
+type TraceInjector interface {
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Span attribute keys set by AdminAPI.
+const (
+	AttrOperation       = "rgw.operation"
+	AttrUID             = "rgw.uid"
+	AttrBucket          = "rgw.bucket"
+	AttrMetadataSection = "rgw.metadata.section"
+	AttrMetadataKey     = "rgw.metadata.key"
+	AttrErrorCode       = "rgw.error_code"
+	AttrRetryCount      = "rgw.retry_count"
+	AttrHTTPMethod      = "http.method"
+	AttrHTTPStatusCode  = "http.status_code"
+)
+
+// NoopTracer - a Tracer that does nothing.  This is the default.
+type NoopTracer struct{}
+
+// Start - implements Tracer
+func (NoopTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+// callInfo - per call state shared between call() and the transport.
+type callInfo struct {
+	op       string
+	attempts int
+	status   int
+}
+
+type callInfoKey struct{}
+
+func withCallInfo(ctx context.Context, op string) (context.Context, *callInfo) {
+	ci := &callInfo{op: op}
+	return context.WithValue(ctx, callInfoKey{}, ci), ci
+}
+
+func callInfoFrom(ctx context.Context) *callInfo {
+	ci, _ := ctx.Value(callInfoKey{}).(*callInfo)
+	return ci
+}
+
+// setRequestAttributes - describe what the call is about, from the path and
+// the encoded query.  Only identifiers are recorded, never keys.
+func setRequestAttributes(span Span, op, method, path string, q url.Values) {
+	span.SetAttribute(AttrOperation, op)
+	span.SetAttribute(AttrHTTPMethod, method)
+	if uid := q.Get("uid"); uid != "" {
+		span.SetAttribute(AttrUID, uid)
+	}
+	if bucket := q.Get("bucket"); bucket != "" {
+		span.SetAttribute(AttrBucket, bucket)
+	}
+	if p := strings.TrimLeft(path, "/"); strings.HasPrefix(p, "metadata/") {
+		span.SetAttribute(AttrMetadataSection, strings.TrimPrefix(p, "metadata/"))
+		if key := q.Get("key"); key != "" {
+			span.SetAttribute(AttrMetadataKey, key)
+		}
+	}
+}
+
+// setResultAttributes - record the outcome of the call.
+func setResultAttributes(span Span, ci *callInfo, err error) {
+	if ci.status != 0 {
+		span.SetAttribute(AttrHTTPStatusCode, ci.status)
+	}
+	if ci.attempts > 1 {
+		span.SetAttribute(AttrRetryCount, ci.attempts-1)
+	}
+	if err == nil {
+		return
+	}
+	var rerr *RGWError
+	if errors.As(err, &rerr) {
+		if rerr.Code != "" {
+			span.SetAttribute(AttrErrorCode, rerr.Code)
+		}
+		if ci.status == 0 {
+			span.SetAttribute(AttrHTTPStatusCode, rerr.StatusCode)
+		}
+	}
+	span.RecordError(err)
+}
@@ -0,0 +1,176 @@
+package radosgwadmin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/myENA/restclient"
+	"github.com/stretchr/testify/suite"
+)
+
+// memSpan - a finished or in flight span held by memTracer.
+type memSpan struct {
+	name     string
+	id       int
+	parentID int
+	attrs    map[string]interface{}
+	errs     []error
+	ended    bool
+}
+
+// memTracer - in memory exporter, records every span.
+type memTracer struct {
+	mu    sync.Mutex
+	spans []*memSpan
+}
+
+type memSpanKey struct{}
+
+func (mt *memTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	s := &memSpan{name: spanName, id: len(mt.spans) + 1, attrs: map[string]interface{}{}}
+	if parent, ok := ctx.Value(memSpanKey{}).(*memSpan); ok {
+		s.parentID = parent.id
+	}
+	mt.spans = append(mt.spans, s)
+	return context.WithValue(ctx, memSpanKey{}, s), s
+}
+
+func (mt *memTracer) Inject(ctx context.Context, h http.Header) {
+	if s, ok := ctx.Value(memSpanKey{}).(*memSpan); ok {
+		h.Set("Traceparent", fmt.Sprintf("span-%d", s.id))
+	}
+}
+
+func (ms *memSpan) SetAttribute(key string, value interface{}) { ms.attrs[key] = value }
+func (ms *memSpan) RecordError(err error)                      { ms.errs = append(ms.errs, err) }
+func (ms *memSpan) End()                                       { ms.ended = true }
+
+type TracingSuite struct {
+	suite.Suite
+	tracer      *memTracer
+	aa          *AdminAPI
+	srv         *httptest.Server
+	failures    int
+	traceparent []string
+}
+
+func (ts *TracingSuite) SetupTest() {
+	ts.tracer = &memTracer{}
+	ts.traceparent = nil
+	ts.failures = 0
+	ts.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.traceparent = append(ts.traceparent, r.Header.Get("Traceparent"))
+		if ts.failures > 0 {
+			ts.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"Code":"SlowDown"}`)
+			return
+		}
+		switch {
+		case r.URL.Query().Get("uid") == "nobody":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"Code":"NoSuchUser"}`)
+		case r.URL.Path == "/admin/metadata/bucket":
+			fmt.Fprint(w, `{"key":"bucket:b1","data":{"bucket":{"name":"b1"}}}`)
+		case r.URL.Path == "/admin/bucket":
+			fmt.Fprint(w, `{"bucket":"b1"}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	var err error
+	ts.aa, err = NewAdminAPI(&Config{
+		ServerURL:       ts.srv.URL,
+		AdminPath:       "admin",
+		AccessKeyID:     "a",
+		SecretAccessKey: "b",
+		Tracer:          ts.tracer,
+		Retry:           RetryPolicy{BaseDelay: restclient.Duration(1)},
+	})
+	ts.Require().NoError(err)
+}
+
+func (ts *TracingSuite) TearDownTest() {
+	ts.srv.Close()
+}
+
+func (ts *TracingSuite) lastSpan() *memSpan {
+	ts.Require().NotEmpty(ts.tracer.spans)
+	return ts.tracer.spans[len(ts.tracer.spans)-1]
+}
+
+func (ts *TracingSuite) TestSpans() {
+	ctx := context.Background()
+
+	_, err := ts.aa.BucketStats(ctx, UserID{}, BucketRef{Name: "b1"})
+	ts.NoError(err)
+	s := ts.lastSpan()
+	ts.Equal("radosgwadmin.BucketStats", s.name)
+	ts.True(s.ended)
+	ts.Equal("BucketStats", s.attrs[AttrOperation])
+	ts.Equal("GET", s.attrs[AttrHTTPMethod])
+	ts.Equal("b1", s.attrs[AttrBucket])
+	ts.Equal(200, s.attrs[AttrHTTPStatusCode])
+	ts.NotContains(s.attrs, AttrRetryCount)
+	ts.Empty(s.errs)
+
+	_, err = ts.aa.MGetBucket(ctx, BucketRef{Name: "b1"})
+	ts.NoError(err)
+	s = ts.lastSpan()
+	ts.Equal("radosgwadmin.MGetBucket", s.name)
+	ts.Equal("bucket", s.attrs[AttrMetadataSection])
+	ts.Equal("b1", s.attrs[AttrMetadataKey])
+
+	_, err = ts.aa.UserInfo(ctx, UserID{ID: "nobody"}, false)
+	ts.Error(err)
+	s = ts.lastSpan()
+	ts.Equal("radosgwadmin.UserInfo", s.name)
+	ts.Equal("nobody", s.attrs[AttrUID])
+	ts.Equal(404, s.attrs[AttrHTTPStatusCode])
+	ts.Equal("NoSuchUser", s.attrs[AttrErrorCode])
+	ts.Len(s.errs, 1)
+}
+
+func (ts *TracingSuite) TestRetryCount() {
+	ts.failures = 2
+	_, err := ts.aa.MListUsers(context.Background())
+	ts.NoError(err)
+	s := ts.lastSpan()
+	ts.Equal("radosgwadmin.MListUsers", s.name)
+	ts.Equal("user", s.attrs[AttrMetadataSection])
+	ts.Equal(2, s.attrs[AttrRetryCount])
+	ts.Equal(200, s.attrs[AttrHTTPStatusCode])
+}
+
+func (ts *TracingSuite) TestPropagation() {
+	ctx, parent := ts.tracer.Start(context.Background(), "caller")
+	_, err := ts.aa.KeyCreate(ctx, &KeyCreateRequest{UID: UserID{ID: "someone"}, AccessKey: "AKID", SecretKey: "supersecret"})
+	ts.NoError(err)
+	s := ts.lastSpan()
+	ts.Equal("radosgwadmin.KeyCreate", s.name)
+	ts.Equal(parent.(*memSpan).id, s.parentID, "span should be a child of the caller's span")
+	ts.Equal([]string{fmt.Sprintf("span-%d", s.id)}, ts.traceparent, "trace context not injected")
+
+	for k, v := range s.attrs {
+		ts.NotEqual("supersecret", v, "secret recorded in attribute %s", k)
+		ts.NotEqual("AKID", v, "access key recorded in attribute %s", k)
+	}
+}
+
+func (ts *TracingSuite) TestNoopDefault() {
+	aa, err := NewAdminAPI(&Config{ServerURL: ts.srv.URL, AdminPath: "admin"})
+	ts.Require().NoError(err)
+	ts.IsType(NoopTracer{}, aa.tracer)
+	_, err = aa.MListUsers(context.Background())
+	ts.NoError(err)
+}
+
+func TestTracing(t *testing.T) {
+	suite.Run(t, new(TracingSuite))
+}
@@ -0,0 +1,228 @@
+package radosgwadmin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Middleware - one step of the AdminAPI request pipeline.  It is handed the next
+// step and returns a RoundTripper that does its own work around a call to it.
+// See AdminAPI.Use.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc - adapts an ordinary function to http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip - implements http.RoundTripper
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Use - add middleware to the request pipeline.  Middleware runs in the order
+// added, once for every attempt at a request (so retries and failover are
+// visible), and wraps the built-in signing step: it sees the request before it is
+// signed and the response after.  Error responses come back as a non-nil
+// *RGWError alongside the response, so middleware can inspect the decoded error.
+// Each attempt gets its own copy of the request, which middleware is free to
+// modify in place, to add headers for example.
+//
+// Use is not safe to call concurrently with requests, add middleware when
+// setting up the AdminAPI.
+func (aa *AdminAPI) Use(mw ...Middleware) {
+	aa.middleware = append(aa.middleware, mw...)
+}
+
+// UseSigned - like Use, except that the middleware runs after the signing step
+// and sees the signed request as it is sent to the gateway.  It must not change
+// anything covered by the signature.
+func (aa *AdminAPI) UseSigned(mw ...Middleware) {
+	aa.signedMiddleware = append(aa.signedMiddleware, mw...)
+}
+
+// pipeline - assemble the per attempt pipeline: middleware, signing, signed
+// middleware, then the error decoding step around the base transport.
+func (aa *AdminAPI) pipeline(base http.RoundTripper) http.RoundTripper {
+	rt := decodeErrors(base)
+	for i := len(aa.signedMiddleware) - 1; i >= 0; i-- {
+		rt = aa.signedMiddleware[i](rt)
+	}
+	rt = aa.signer(rt)
+	for i := len(aa.middleware) - 1; i >= 0; i-- {
+		rt = aa.middleware[i](rt)
+	}
+	return rt
+}
+
+// signer - the built-in signing middleware.
+func (aa *AdminAPI) signer(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if err := aa.sign(req); err != nil {
+			return nil, err
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// endpointError - a failure to talk to the gateway at all, as opposed to an
+// error response from it.  These count against the endpoint's health.
+type endpointError struct {
+	err error
+}
+
+func (ee *endpointError) Error() string {
+	return ee.err.Error()
+}
+
+func (ee *endpointError) Unwrap() error {
+	return ee.err
+}
+
+// decodeErrors - the innermost step of the pipeline.  Responses with a status of
+// 400 or more are returned along with the decoded *RGWError, and have their body
+// replaced with a buffered copy.
+func decodeErrors(base http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return nil, &endpointError{err}
+		}
+		return checkStatus(resp)
+	})
+}
+
+// checkStatus - decode the error in a response with a status of 400 or more, as
+// described for decodeErrors.
+func checkStatus(resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode < 400 {
+		return resp, nil
+	}
+	rerr := decodeErrorResponse(resp).(*RGWError)
+	_ = resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(rerr.Body))
+	rerr.resp = resp
+	return resp, rerr
+}
+
+// adminTransport - the http.RoundTripper installed on the AdminAPI's http client.
+// It sends each request to an endpoint from the pool through the pipeline.
+// Retryable requests that fail with a connection error or a transient 5xx
+// response are failed over to the next endpoint straight away, and retried with
+// backoff once every endpoint has had a go, as per the retry policy.
+type adminTransport struct {
+	aa   *AdminAPI
+	base http.RoundTripper
+}
+
+// RoundTrip - implements http.RoundTripper
+func (at *adminTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := at.roundTrip(req)
+	if err == nil {
+		return resp, nil
+	}
+	// Error responses go back to restclient as plain responses, to be decoded
+	// by its ErrorResponseCallback.
+	var rerr *RGWError
+	if errors.As(err, &rerr) && rerr.resp != nil {
+		return rerr.resp, nil
+	}
+	discard(resp)
+	var eerr *endpointError
+	if errors.As(err, &eerr) {
+		return nil, eerr.err
+	}
+	return nil, err
+}
+
+func (at *adminTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	pool := at.aa.endpoints
+	policy := at.aa.retry
+	pipeline := at.aa.pipeline(at.base)
+	maxAttempts := policy.MaxAttempts
+	if n := len(pool.endpoints); n > maxAttempts {
+		maxAttempts = n
+	}
+	retryable := isRetryable(req)
+	ci := callInfoFrom(ctx)
+	tried := make(map[*endpoint]bool)
+	backoffs := 0
+	for attempt := 1; ; attempt++ {
+		if len(tried) == len(pool.endpoints) {
+			tried = make(map[*endpoint]bool)
+		}
+		e := pool.pick(tried)
+		tried[e] = true
+		resp, err := at.try(pipeline, req, e)
+		if ci != nil {
+			ci.attempts = attempt
+			ci.status = 0
+			if resp != nil {
+				ci.status = resp.StatusCode
+			}
+		}
+		if !endpointFailed(err) {
+			pool.markHealthy(e)
+			return resp, err
+		}
+		// Don't blame the endpoint for our own context being done.
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		pool.markFailed(e)
+		if !retryable || attempt >= maxAttempts {
+			return resp, err
+		}
+		if len(tried) == len(pool.endpoints) {
+			backoffs++
+			if !sleepCtx(ctx, policy.backoff(backoffs)) {
+				return resp, err
+			}
+		}
+		discard(resp)
+	}
+}
+
+// endpointFailed - whether err means the endpoint is unhealthy: it could not be
+// reached, or it answered with a transient server error.
+func endpointFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+	var rerr *RGWError
+	if errors.As(err, &rerr) {
+		return retryableStatus(rerr.StatusCode)
+	}
+	var eerr *endpointError
+	return errors.As(err, &eerr)
+}
+
+// try - send a copy of req to e through the pipeline.
+func (at *adminTransport) try(pipeline http.RoundTripper, req *http.Request, e *endpoint) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	at.aa.endpoints.rebase(r.URL, e)
+	r.Host = ""
+	if inj, ok := at.aa.tracer.(TraceInjector); ok {
+		inj.Inject(r.Context(), r.Header)
+	}
+	return pipeline.RoundTrip(r)
+}
+
+// discard - drain and close the body of a response that is being thrown away,
+// so that the connection can be reused.
+func discard(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,387 @@
+package radosgwadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/myENA/restclient"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeGateway - counts hits, and answers with a fixed status.
+type fakeGateway struct {
+	*httptest.Server
+	hits    int32
+	status  int32
+	failFor int32 // fail this many requests with a 503 before using status
+	paths   []string
+}
+
+func newFakeGateway(status int) *fakeGateway {
+	fg := &fakeGateway{status: int32(status)}
+	fg.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fg.hits, 1)
+		fg.paths = append(fg.paths, r.URL.Path)
+		status := int(atomic.LoadInt32(&fg.status))
+		if atomic.AddInt32(&fg.failFor, -1) >= 0 {
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		if status >= 400 {
+			fmt.Fprintf(w, `{"Code":"%s"}`, map[bool]string{true: "SlowDown", false: "InternalError"}[status == 503])
+			return
+		}
+		if r.Method != http.MethodDelete {
+			fmt.Fprint(w, `{"user_id":"someone","display_name":"Some One"}`)
+		}
+	}))
+	return fg
+}
+
+func (fg *fakeGateway) count() int {
+	return int(atomic.LoadInt32(&fg.hits))
+}
+
+func (fg *fakeGateway) setStatus(status int) {
+	atomic.StoreInt32(&fg.status, int32(status))
+}
+
+type TransportSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func (ts *TransportSuite) SetupTest() {
+	ts.ctx = context.Background()
+}
+
+func (ts *TransportSuite) newAdminAPI(sel EndpointSelection, gws ...*fakeGateway) *AdminAPI {
+	var urls []string
+	for _, gw := range gws {
+		urls = append(urls, gw.URL)
+	}
+	aa, err := NewAdminAPI(&Config{
+		ServerURLs:        urls,
+		AdminPath:         "admin",
+		AccessKeyID:       "a",
+		SecretAccessKey:   "b",
+		EndpointSelection: sel,
+	})
+	ts.Require().NoError(err)
+	return aa
+}
+
+func (ts *TransportSuite) TestFailover() {
+	down, up := newFakeGateway(503), newFakeGateway(200)
+	defer down.Close()
+	defer up.Close()
+	aa := ts.newAdminAPI(EndpointPriority, down, up)
+
+	uir, err := aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+	ts.Require().NoError(err)
+	ts.Equal("someone", uir.UserID)
+	ts.Equal(1, down.count())
+	ts.Equal(1, up.count())
+
+	// The failed gateway is ejected, so the next call goes straight to the healthy one.
+	_, err = aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+	ts.NoError(err)
+	ts.Equal(1, down.count())
+	ts.Equal(2, up.count())
+
+	// Once the ejection expires and it recovers, priority order applies again.
+	down.setStatus(200)
+	aa.endpoints.now = func() time.Time { return time.Now().Add(DefaultEjectDuration + time.Second) }
+	_, err = aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+	ts.NoError(err)
+	ts.Equal(2, down.count())
+	ts.Equal(2, up.count())
+}
+
+func (ts *TransportSuite) TestAllDown() {
+	gw1, gw2 := newFakeGateway(500), newFakeGateway(503)
+	defer gw1.Close()
+	defer gw2.Close()
+	aa := ts.newAdminAPI(EndpointPriority, gw1, gw2)
+	aa.retry.MaxAttempts = 1
+
+	_, err := aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+	ts.True(errors.Is(err, ErrSlowDown), "expected the last gateway's error, got %v", err)
+	ts.Equal(1, gw1.count())
+	ts.Equal(1, gw2.count())
+}
+
+func (ts *TransportSuite) TestConnectionError() {
+	dead := newFakeGateway(200)
+	dead.Close()
+	up := newFakeGateway(200)
+	defer up.Close()
+	aa := ts.newAdminAPI(EndpointPriority, dead, up)
+
+	_, err := aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+	ts.NoError(err)
+	ts.Equal(1, up.count())
+	ts.True(aa.endpoints.endpoints[0].ejectedUntil.After(time.Now()), "dead endpoint not ejected")
+}
+
+func (ts *TransportSuite) TestNoFailoverForMutations() {
+	down, up := newFakeGateway(503), newFakeGateway(200)
+	defer down.Close()
+	defer up.Close()
+	aa := ts.newAdminAPI(EndpointPriority, down, up)
+
+	err := aa.UserRm(ts.ctx, UserID{ID: "someone"}, false)
+	ts.True(errors.Is(err, ErrSlowDown), "expected ErrSlowDown, got %v", err)
+	ts.Equal(1, down.count())
+	ts.Equal(0, up.count(), "mutation should not have been retried")
+
+	// But the failed gateway is still ejected for the next request.
+	ts.NoError(aa.UserRm(ts.ctx, UserID{ID: "someone"}, false))
+	ts.Equal(1, down.count())
+	ts.Equal(1, up.count())
+}
+
+func (ts *TransportSuite) TestRoundRobin() {
+	gw1, gw2, gw3 := newFakeGateway(200), newFakeGateway(200), newFakeGateway(200)
+	defer gw1.Close()
+	defer gw2.Close()
+	defer gw3.Close()
+	aa := ts.newAdminAPI("", gw1, gw2, gw3)
+
+	for i := 0; i < 6; i++ {
+		_, err := aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+		ts.NoError(err)
+	}
+	ts.Equal(2, gw1.count())
+	ts.Equal(2, gw2.count())
+	ts.Equal(2, gw3.count())
+}
+
+func (ts *TransportSuite) TestEndpointPaths() {
+	gw1, gw2 := newFakeGateway(503), newFakeGateway(200)
+	defer gw1.Close()
+	defer gw2.Close()
+	aa, err := NewAdminAPI(&Config{
+		ServerURL:         gw1.URL + "/",
+		ServerURLs:        []string{gw2.URL + "/rgw2/"},
+		AdminPath:         "/admin/",
+		EndpointSelection: EndpointPriority,
+	})
+	ts.Require().NoError(err)
+	_, err = aa.Quotas(ts.ctx, UserID{ID: "someone"})
+	ts.NoError(err)
+	ts.Equal([]string{"/admin/user"}, gw1.paths)
+	ts.Equal([]string{"/rgw2/admin/user"}, gw2.paths)
+
+	_, err = NewAdminAPI(&Config{AdminPath: "admin"})
+	ts.Error(err, "expected error with no server urls")
+	_, err = NewAdminAPI(&Config{ServerURL: gw1.URL, EndpointSelection: "random"})
+	ts.Error(err, "expected error for unknown selection")
+}
+
+func (ts *TransportSuite) TestRetryReads() {
+	gw := newFakeGateway(200)
+	defer gw.Close()
+	aa := ts.newAdminAPI("", gw)
+	aa.retry.BaseDelay = restclient.Duration(time.Millisecond)
+
+	gw.failFor = 2
+	_, err := aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+	ts.NoError(err)
+	ts.Equal(3, gw.count())
+
+	// Out of attempts.
+	gw.failFor = 3
+	_, err = aa.BucketStats(ts.ctx, UserID{}, BucketRef{Name: "somebucket"})
+	ts.True(errors.Is(err, ErrSlowDown), "expected ErrSlowDown, got %v", err)
+	ts.Equal(6, gw.count())
+
+	// Reads can opt out.
+	gw.failFor = 1
+	_, err = aa.MListUsers(WithoutRetry(ts.ctx))
+	ts.Error(err)
+	ts.Equal(7, gw.count())
+
+	// Client errors are never retried.
+	gw.failFor = 0
+	gw.setStatus(404)
+	_, err = aa.Usage(ts.ctx, &UsageRequest{})
+	ts.Error(err)
+	ts.Equal(8, gw.count())
+}
+
+func (ts *TransportSuite) TestRetryMutationsOptIn() {
+	gw := newFakeGateway(200)
+	defer gw.Close()
+	aa := ts.newAdminAPI("", gw)
+	aa.retry.BaseDelay = restclient.Duration(time.Millisecond)
+
+	gw.failFor = 1
+	_, err := aa.KeyCreate(ts.ctx, &KeyCreateRequest{UID: UserID{ID: "someone"}})
+	ts.True(errors.Is(err, ErrSlowDown), "expected ErrSlowDown, got %v", err)
+	ts.Equal(1, gw.count())
+
+	gw.failFor = 1
+	_, err = aa.UserCreate(WithRetry(ts.ctx), &UserCreateRequest{UID: UserID{ID: "someone"}, DisplayName: "Some One"})
+	ts.NoError(err)
+	ts.Equal(3, gw.count())
+
+	gw.failFor = 1
+	ts.NoError(aa.BucketRm(WithRetry(ts.ctx), BucketRef{Name: "somebucket"}, false))
+	ts.Equal(5, gw.count())
+}
+
+func (ts *TransportSuite) TestRetryDeadline() {
+	gw := newFakeGateway(503)
+	defer gw.Close()
+	aa := ts.newAdminAPI("", gw)
+	aa.retry.BaseDelay = restclient.Duration(time.Second)
+	aa.retry.MaxAttempts = 10
+
+	ctx, cancel := context.WithTimeout(ts.ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := aa.UserInfo(ctx, UserID{ID: "someone"}, false)
+	ts.True(errors.Is(err, ErrSlowDown), "expected the last response error, got %v", err)
+	ts.True(time.Since(start) < 500*time.Millisecond, "retry did not respect the deadline")
+	ts.Equal(1, gw.count())
+}
+
+func (ts *TransportSuite) TestBackoff() {
+	rp := RetryPolicy{
+		BaseDelay: restclient.Duration(100 * time.Millisecond),
+		MaxDelay:  restclient.Duration(time.Second),
+	}.withDefaults()
+	ts.Equal(DefaultMaxAttempts, rp.MaxAttempts)
+	for i := 0; i < 20; i++ {
+		for n, max := range map[int]time.Duration{
+			1:  100 * time.Millisecond,
+			2:  200 * time.Millisecond,
+			3:  400 * time.Millisecond,
+			5:  time.Second,
+			50: time.Second,
+		} {
+			d := rp.backoff(n)
+			ts.True(d >= max/2 && d <= max, "backoff(%d) = %s, expected between %s and %s", n, d, max/2, max)
+		}
+	}
+}
+
+func (ts *TransportSuite) TestMiddleware() {
+	var (
+		order     []string
+		headers   []string
+		statuses  []int
+		codes     []string
+		preAuth   []string
+		postAuths []string
+	)
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get("X-Test"))
+		if r.URL.Query().Get("uid") == "nobody" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"Code":"NoSuchUser"}`)
+			return
+		}
+		fmt.Fprint(w, `{"user_id":"someone"}`)
+	}))
+	defer gw.Close()
+	aa, err := NewAdminAPI(&Config{ServerURL: gw.URL, AdminPath: "admin", AccessKeyID: "a", SecretAccessKey: "b"})
+	ts.Require().NoError(err)
+
+	aa.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			order = append(order, "first")
+			preAuth = append(preAuth, req.Header.Get("Authorization"))
+			req.Header.Set("X-Test", "hello")
+			resp, err := next.RoundTrip(req)
+			statuses = append(statuses, resp.StatusCode)
+			var rerr *RGWError
+			if errors.As(err, &rerr) {
+				codes = append(codes, rerr.Code)
+			}
+			return resp, err
+		})
+	})
+	aa.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			order = append(order, "second")
+			return next.RoundTrip(req)
+		})
+	})
+	aa.UseSigned(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			order = append(order, "signed")
+			postAuths = append(postAuths, req.Header.Get("Authorization"))
+			return next.RoundTrip(req)
+		})
+	})
+
+	_, err = aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+	ts.NoError(err)
+	_, err = aa.UserInfo(ts.ctx, UserID{ID: "nobody"}, false)
+	ts.True(errors.Is(err, ErrNoSuchUser), "decoded error should still reach the caller, got %v", err)
+
+	ts.Equal([]string{"first", "second", "signed", "first", "second", "signed"}, order)
+	ts.Equal([]string{"hello", "hello"}, headers)
+	ts.Equal([]string{"", ""}, preAuth, "middleware added with Use should see the request before signing")
+	ts.Len(postAuths, 2)
+	for _, a := range postAuths {
+		ts.True(strings.HasPrefix(a, "AWS a:"), "signed middleware should see the signature, got %q", a)
+	}
+	ts.Equal([]int{200, 404}, statuses)
+	ts.Equal([]string{"NoSuchUser"}, codes)
+}
+
+func (ts *TransportSuite) TestMiddlewarePerAttempt() {
+	gw := newFakeGateway(200)
+	defer gw.Close()
+	aa := ts.newAdminAPI("", gw)
+	aa.retry.BaseDelay = restclient.Duration(time.Millisecond)
+
+	var seen []int
+	aa.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			seen = append(seen, resp.StatusCode)
+			return resp, err
+		})
+	})
+	gw.failFor = 1
+	_, err := aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+	ts.NoError(err)
+	ts.Equal([]int{503, 200}, seen)
+}
+
+func (ts *TransportSuite) TestMiddlewareShortCircuit() {
+	gw := newFakeGateway(200)
+	defer gw.Close()
+	aa := ts.newAdminAPI("", gw)
+
+	aa.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"user_id":"canned"}`)),
+				Request:    req,
+			}, nil
+		})
+	})
+	uir, err := aa.UserInfo(ts.ctx, UserID{ID: "someone"}, false)
+	ts.NoError(err)
+	ts.Equal("canned", uir.UserID)
+	ts.Equal(0, gw.count())
+}
+
+func TestTransport(t *testing.T) {
+	suite.Run(t, new(TransportSuite))
+}
@@ -6,7 +6,7 @@ import (
 
 // UsageRequest - desribes a usage request
 type UsageRequest struct {
-	UID         string    `url:"uid,omitempty"`
+	UID         UserID    `url:"uid,omitempty"`
 	Start       RadosTime `url:"start,omitempty"`
 	End         RadosTime `url:"end,omitempty"`
 	ShowEntries bool      `url:"show-entries,omitempty"`
@@ -15,7 +15,7 @@ type UsageRequest struct {
 
 // TrimUsageRequest - describes a trim usage request
 type TrimUsageRequest struct {
-	UID       string    `url:"uid,omitempty"`
+	UID       UserID    `url:"uid,omitempty"`
 	Start     RadosTime `url:"start,omitempty"`
 	End       RadosTime `url:"end,omitempty"`
 	RemoveAll bool      `url:"remove-all,omitempty"`
@@ -23,7 +23,7 @@ type TrimUsageRequest struct {
 
 // UsageTrim - trim usage data
 func (aa *AdminAPI) UsageTrim(ctx context.Context, treq *TrimUsageRequest) error {
-	err := aa.Delete(ctx, "/usage", treq, nil)
+	err := aa.delete(ctx, "UsageTrim", "/usage", treq, nil)
 	return err
 }
 
@@ -31,7 +31,7 @@ func (aa *AdminAPI) UsageTrim(ctx context.Context, treq *TrimUsageRequest) error
 func (aa *AdminAPI) Usage(ctx context.Context, ureq *UsageRequest) (*UsageResponse, error) {
 	uresp := new(UsageResponse)
 
-	err := aa.Get(ctx, "/usage", ureq, uresp)
+	err := aa.get(ctx, "Usage", "/usage", ureq, uresp)
 	return uresp, err
 }
 
@@ -2,26 +2,42 @@ package radosgwadmin
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 )
 
-// UserCreateRequest - describes what to do in a user create operation.
+// UserCreateRequest - describes what to do in a user create operation.  UID,
+// here and in the other request types, is sent in the gateway's "tenant$id"
+// form, so the user's tenant is UID.Tenant.
 type UserCreateRequest struct {
-	UID         string    `url:"uid" validate:"required"`
+	UID         UserID    `url:"uid" validate:"required"`
 	DisplayName string    `url:"display-name" validate:"required"`
 	Email       string    `url:"email,omitempty" validate:"omitempty,email"`
 	KeyType     string    `url:"key-type,omitempty" validate:"omitempty,eq=swift|eq=s3"`
 	AccessKey   string    `url:"access-key,omitempty"`
 	SecretKey   string    `url:"secret-key,omitempty"`
 	UserCaps    []UserCap `url:"user-caps,omitempty,semicolon" validate:"omitempty,dive"`
-	Tenant      string    `url:"tenant,omitempty"`
 	GenerateKey *bool     `url:"generate-key,omitempty"` // This defaults to true, preserving that behavior
 	MaxBuckets  int       `url:"max-buckets,omitempty"`
 	Suspended   bool      `url:"suspended,omitempty"`
+	// OpMask - zero for the gateway's default, OpAll.
+	OpMask              OpMask   `url:"op-mask,omitempty"`
+	DefaultPlacement    string   `url:"default-placement,omitempty"`
+	PlacementTags       []string `url:"placement-tags,omitempty,comma"`
+	DefaultStorageClass string   `url:"default-storage-class,omitempty"`
+	Admin               bool     `url:"admin,omitempty"`
+	System              bool     `url:"system,omitempty"` // e.g. for multisite sync users
 }
 
 // UserModifyRequest - modify user request type.
 type UserModifyRequest struct {
-	UID         string    `url:"uid" validate:"required"`
+	UID         UserID    `url:"uid" validate:"required"`
 	DisplayName string    `url:"display-name,omitempty"`
 	Email       string    `url:"email,omitempty"`
 	KeyType     string    `url:"key-type,omitempty" validate:"omitempty,eq=swift|eq=s3"`
@@ -30,7 +46,15 @@ type UserModifyRequest struct {
 	UserCaps    []UserCap `url:"user-caps,omitempty,semicolon" validate:"omitempty,dive"`
 	GenerateKey bool      `url:"generate-key,omitempty"` // This defaults to false, preserving that behavior
 	MaxBuckets  int       `url:"max-buckets,omitempty"`
-	Suspended   bool      `url:"suspended,omitempty"`
+	Suspended   *bool     `url:"suspended,omitempty"` // nil leaves it as is, use TrueRef or FalseRef
+	// OpMask, DefaultPlacement, PlacementTags, DefaultStorageClass - zero values
+	// leave them as they are.
+	OpMask              OpMask   `url:"op-mask,omitempty"`
+	DefaultPlacement    string   `url:"default-placement,omitempty"`
+	PlacementTags       []string `url:"placement-tags,omitempty,comma"`
+	DefaultStorageClass string   `url:"default-storage-class,omitempty"`
+	Admin               *bool    `url:"admin,omitempty"`  // nil leaves it as is
+	System              *bool    `url:"system,omitempty"` // nil leaves it as is
 }
 
 type userInfoRequest struct {
@@ -38,6 +62,16 @@ type userInfoRequest struct {
 	Stats bool   `url:"stats,omitempty"`
 }
 
+type userByKeyRequest struct {
+	AccessKey string `url:"access-key" validate:"required"`
+	Stats     bool   `url:"stats,omitempty"`
+}
+
+type userByEmailRequest struct {
+	Email string `url:"email" validate:"required"`
+	Stats bool   `url:"stats,omitempty"`
+}
+
 type userDeleteRequest struct {
 	UID       string `url:"uid" validate:"required"`
 	PurgeData bool   `url:"purge-data"`
@@ -45,27 +79,149 @@ type userDeleteRequest struct {
 
 // UserCapsRequest - this is passed to CapsAdd() and CapsRm()
 type UserCapsRequest struct {
-	UID      string    `url:"uid" validate:"required"`
+	UID      UserID    `url:"uid" validate:"required"`
 	UserCaps []UserCap `url:"user-caps,semicolon" validate:"required,dive"`
 }
 
 // UserInfoResponse - response from a user info request.
 type UserInfoResponse struct {
-	Tenant      string     `json:"tenant"`
-	UserID      string     `json:"user_id"`
-	DisplayName string     `json:"display_name"`
-	Email       string     `json:"email"`
-	Suspended   int        `json:"suspended"` // should be bool
-	MaxBuckets  int        `json:"max_buckets"`
-	SubUsers    []SubUser  `json:"subusers"`
-	Keys        []UserKey  `json:"keys"`
-	SwiftKeys   []SwiftKey `json:"swift_keys"`
-	Caps        []UserCap  `json:"caps"`
+	Tenant              string       `json:"tenant"`
+	UserID              string       `json:"user_id"`
+	DisplayName         string       `json:"display_name"`
+	Email               string       `json:"email"`
+	Suspended           bool         `json:"suspended"` // 0 or 1 on the wire
+	MaxBuckets          int          `json:"max_buckets"`
+	SubUsers            []SubUser    `json:"subusers"`
+	Keys                []UserKey    `json:"keys"`
+	SwiftKeys           []SwiftKey   `json:"swift_keys"`
+	Caps                []UserCap    `json:"caps"`
+	OpMask              OpMask       `json:"op_mask"`
+	DefaultPlacement    string       `json:"default_placement"`
+	DefaultStorageClass string       `json:"default_storage_class"`
+	PlacementTags       []string     `json:"placement_tags"`
+	BucketQuota         QuotaMeta    `json:"bucket_quota"`
+	UserQuota           QuotaMeta    `json:"user_quota"`
+	TempURLKeys         []TempURLKey `json:"temp_url_keys"`
+	Type                string       `json:"type"`
+	MfaIDs              []string     `json:"mfa_ids"`
+	System              bool         `json:"system"` // only sent when true
+	Admin               bool         `json:"admin"`  // only sent when true
 	// Stats is returned if the stats flag is passed to the user info request.
-	Stats *UserStats `json:"stats"`
+	Stats *UserStats `json:"stats,omitempty"`
+	// Extra - any other fields the gateway returned, e.g. from newer releases,
+	// kept as is so that they survive being marshaled back.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// TempURLKey - a swift temp url key.
+type TempURLKey struct {
+	Key int    `json:"key"`
+	Val Secret `json:"val"`
+}
+
+// userInfoJSON - UserInfoResponse without its json methods.
+type userInfoJSON UserInfoResponse
+
+// userInfoFields - the json names of the modeled UserInfoResponse fields.
+var userInfoFields = jsonFieldNames(reflect.TypeOf(userInfoJSON{}))
+
+// UnmarshalJSON - implements json.Unmarshaler, accepting the gateway's forms of
+// the flags and keeping unknown fields in Extra.
+func (uir *UserInfoResponse) UnmarshalJSON(b []byte) error {
+	aux := struct {
+		*userInfoJSON
+		Suspended looseBool `json:"suspended"`
+		System    looseBool `json:"system"`
+		Admin     looseBool `json:"admin"`
+	}{userInfoJSON: (*userInfoJSON)(uir)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	uir.Suspended, uir.System, uir.Admin = bool(aux.Suspended), bool(aux.System), bool(aux.Admin)
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	uir.Extra = nil
+	for k, v := range raw {
+		if !userInfoFields[k] {
+			if uir.Extra == nil {
+				uir.Extra = map[string]json.RawMessage{}
+			}
+			uir.Extra[k] = v
+		}
+	}
+	return nil
+}
+
+// MarshalJSON - implements json.Marshaler, in the form the gateway uses,
+// including the fields in Extra.
+func (uir UserInfoResponse) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		*userInfoJSON
+		Suspended int  `json:"suspended"`
+		System    bool `json:"system,omitempty"`
+		Admin     bool `json:"admin,omitempty"`
+	}{userInfoJSON: (*userInfoJSON)(&uir), System: uir.System, Admin: uir.Admin}
+	if uir.Suspended {
+		aux.Suspended = 1
+	}
+	b, err := json.Marshal(aux)
+	if err != nil || len(uir.Extra) == 0 {
+		return b, err
+	}
+	all := map[string]json.RawMessage{}
+	if err = json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	for k, v := range uir.Extra {
+		if _, ok := all[k]; !ok {
+			all[k] = v
+		}
+	}
+	return json.Marshal(all)
+}
+
+// looseBool - a flag as the gateway sends it, a bool, a number or a string
+// holding either.
+type looseBool bool
+
+func (lb *looseBool) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	switch strings.ToLower(s) {
+	case "true":
+		*lb = true
+	case "false", "", "null":
+		*lb = false
+	default:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("radosgwadmin: bad flag %s", b)
+		}
+		*lb = n != 0
+	}
+	return nil
+}
+
+// jsonFieldNames - the names struct type t's fields are encoded as.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
 }
 
-//UserStats - statistics for a user
+// UserStats - statistics for a user
 type UserStats struct {
 	Size           int `json:"size"`
 	SizeActual     int `json:"size_actual"`
@@ -82,23 +238,39 @@ type SubUser struct {
 	Permissions string `json:"permissions"`
 }
 
-// UserKey - user key information.
+// UserKey - user key information.  Active and CreateDate are only reported by
+// newer releases, and nil otherwise.
 type UserKey struct {
-	User      string `json:"user"`
-	AccessKey string `json:"access_key"`
-	SecretKey string `json:"secret_key"`
+	User       string     `json:"user"`
+	AccessKey  string     `json:"access_key"`
+	SecretKey  Secret     `json:"secret_key"`
+	Active     *bool      `json:"active,omitempty"`
+	CreateDate *RadosTime `json:"create_date,omitempty"`
 }
 
-// SwiftKey - swift key information
+// IsActive - false only if the key was deactivated, see KeyModify.
+func (uk UserKey) IsActive() bool {
+	return uk.Active == nil || *uk.Active
+}
+
+// SwiftKey - swift key information, see UserKey.
 type SwiftKey struct {
-	User      string `json:"user"`
-	SecretKey string `json:"secret_key"`
+	User       string     `json:"user"`
+	SecretKey  Secret     `json:"secret_key"`
+	Active     *bool      `json:"active,omitempty"`
+	CreateDate *RadosTime `json:"create_date,omitempty"`
 }
 
-// UserCap - desribes user capabilities / permissions.
+// IsActive - false only if the key was deactivated, see KeyModify.
+func (sk SwiftKey) IsActive() bool {
+	return sk.Active == nil || *sk.Active
+}
+
+// UserCap - desribes user capabilities / permissions.  Permission is checked
+// with ParseCapPerm when a request is validated.
 type UserCap struct {
-	Type       string `json:"type" validate:"required,eq=users|eq=buckets|eq=metadata|eq=usage|eq=zone"`
-	Permission string `json:"perm" validate:"required,eq=*|eq=read|eq=write|eq=read0x2Cwrite"`
+	Type       string `json:"type" validate:"required,excludesall=;="`
+	Permission string `json:"perm" validate:"required"`
 }
 
 // String - Implement Stringer
@@ -108,7 +280,7 @@ func (uc UserCap) String() string {
 
 // SubUserCreateModifyRequest - Create or modify sub user request.
 type SubUserCreateModifyRequest struct {
-	UID            string `url:"uid" validate:"required"`
+	UID            UserID `url:"uid" validate:"required"`
 	SubUser        string `url:"subuser" validate:"required"`
 	SecretKey      string `url:"secret-key,omitempty"`
 	KeyType        string `url:"key-type,omitempty" validate:"omitempty,eq=s3|eq=swift"`
@@ -118,7 +290,7 @@ type SubUserCreateModifyRequest struct {
 
 // SubUserRmRequest - if PurgeKeys is nil, defaults to true
 type SubUserRmRequest struct {
-	UID       string `url:"uid" validate:"required"`
+	UID       UserID `url:"uid" validate:"required"`
 	SubUser   string `url:"subuser" validate:"required"`
 	PurgeKeys *bool  `url:"purge-keys,omitempty"` // Default true
 }
@@ -126,51 +298,164 @@ type SubUserRmRequest struct {
 // UserInfo - get user information about uid.  If stats is true, then return
 // quota statistics.  This will return a not found error if no statistics
 // are available, even if the user exists.
-func (aa *AdminAPI) UserInfo(ctx context.Context, uid string, stats bool) (*UserInfoResponse, error) {
-	uir := &userInfoRequest{uid, stats}
+func (aa *AdminAPI) UserInfo(ctx context.Context, uid UserID, stats bool) (*UserInfoResponse, error) {
+	uir := &userInfoRequest{uid.String(), stats}
 	resp := &UserInfoResponse{}
 
-	err := aa.Get(ctx, "/user", uir, resp)
+	err := aa.get(ctx, "UserInfo", "/user", uir, resp)
 	return resp, err
 }
 
+// UserInfoByAccessKey - get user information about the owner of an s3 access
+// key, which may belong to one of its subusers.  See UserInfo.
+func (aa *AdminAPI) UserInfoByAccessKey(ctx context.Context, accessKey string, stats bool) (*UserInfoResponse, error) {
+	req := &userByKeyRequest{accessKey, stats}
+	resp := &UserInfoResponse{}
+	err := aa.get(ctx, "UserInfoByAccessKey", "/user", req, resp)
+	return resp, err
+}
+
+// UserInfoByEmail - get user information about the user with an email address,
+// compared without regard to case.  See UserInfo.
+//
+// Gateways that cannot look users up by email answer with ErrInvalidArgument,
+// in which case every user's metadata is scanned for the address, with up to
+// Config.ScanConcurrency requests at a time.  That is one request per user, so
+// it can take a while on a large cluster.
+func (aa *AdminAPI) UserInfoByEmail(ctx context.Context, email string, stats bool) (*UserInfoResponse, error) {
+	req := &userByEmailRequest{email, stats}
+	resp := &UserInfoResponse{}
+	err := aa.get(ctx, "UserInfoByEmail", "/user", req, resp)
+	if !errors.Is(err, ErrInvalidArgument) {
+		return resp, err
+	}
+	uid, err := aa.scanForEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return aa.UserInfo(ctx, uid, stats)
+}
+
+// scanForEmail - the user with email, from their metadata.
+func (aa *AdminAPI) scanForEmail(ctx context.Context, email string) (UserID, error) {
+	var found *UserID
+	err := aa.scanUsers(ctx, func(uid UserID, mur *MUserResponse) bool {
+		if strings.EqualFold(mur.Data.Email, email) {
+			found = &uid
+		}
+		return found != nil
+	})
+	switch {
+	case found != nil:
+		return *found, nil
+	case err != nil:
+		return UserID{}, err
+	}
+	return UserID{}, &RGWError{
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Code:       "NoSuchUser",
+		Message:    "no user with email " + email,
+	}
+}
+
+// scanUsers - get the metadata of every user, with up to Config.ScanConcurrency
+// requests at a time, and pass it to visit until that returns true.  visit is
+// not called concurrently.  Users removed since the listing are skipped.
+func (aa *AdminAPI) scanUsers(ctx context.Context, visit func(uid UserID, mur *MUserResponse) (stop bool)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		stopped  bool
+		firstErr error
+	)
+	sem := make(chan struct{}, aa.scanConcurrency)
+	users := aa.Users(ctx)
+scan:
+	for users.Next() {
+		uid, err := ParseUserID(users.Value())
+		if err != nil {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break scan
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			mur, err := aa.MGetUser(ctx, uid)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case stopped:
+			case err == nil:
+				if stopped = visit(uid, mur); stopped {
+					cancel()
+				}
+			case errors.Is(err, ErrNoSuchKey), errors.Is(err, ErrNoSuchUser):
+				// Removed since the listing.
+			case firstErr == nil:
+				firstErr = err
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	switch {
+	case stopped:
+		return nil
+	case firstErr != nil:
+		return firstErr
+	case users.Err() != nil:
+		return users.Err()
+	}
+	return ctx.Err()
+}
+
 // UserCreate - create a user described by cur.
 func (aa *AdminAPI) UserCreate(ctx context.Context, cur *UserCreateRequest) (*UserInfoResponse, error) {
 	resp := &UserInfoResponse{}
-	err := aa.Put(ctx, "/user", cur, nil, resp)
+	err := aa.put(ctx, "UserCreate", "/user", cur, resp)
 	return resp, err
 }
 
 // UserRm - delete user uid
-func (aa *AdminAPI) UserRm(ctx context.Context, uid string, purge bool) error {
-	udr := &userDeleteRequest{uid, purge}
-	return aa.Delete(ctx, "/user", udr, nil)
+func (aa *AdminAPI) UserRm(ctx context.Context, uid UserID, purge bool) error {
+	udr := &userDeleteRequest{uid.String(), purge}
+	return aa.delete(ctx, "UserRm", "/user", udr, nil)
 }
 
 // UserModify - modify a user described by umr
 func (aa *AdminAPI) UserModify(ctx context.Context, umr *UserModifyRequest) (*UserInfoResponse, error) {
 	resp := &UserInfoResponse{}
-	err := aa.Post(ctx, "/user", umr, nil, resp)
+	err := aa.post(ctx, "UserModify", "/user", umr, resp)
 	return resp, err
 }
 
 // SubUserCreate - create a subuser
 func (aa *AdminAPI) SubUserCreate(ctx context.Context, sucr *SubUserCreateModifyRequest) ([]SubUser, error) {
 	resp := []SubUser{}
-	err := aa.Put(ctx, "/user?subuser", sucr, nil, &resp)
+	err := aa.put(ctx, "SubUserCreate", "/user?subuser", sucr, &resp)
 	return resp, err
 }
 
 // SubUserModify - modify a subuser
 func (aa *AdminAPI) SubUserModify(ctx context.Context, sucr *SubUserCreateModifyRequest) ([]SubUser, error) {
 	resp := []SubUser{}
-	err := aa.Post(ctx, "/user?subuser", sucr, nil, &resp)
+	err := aa.post(ctx, "SubUserModify", "/user?subuser", sucr, &resp)
 	return resp, err
 }
 
 // SubUserRm - delete a subuser
 func (aa *AdminAPI) SubUserRm(ctx context.Context, surm *SubUserRmRequest) error {
-	return aa.Delete(ctx, "/user?subuser", surm, nil)
+	return aa.delete(ctx, "SubUserRm", "/user?subuser", surm, nil)
 }
 
 // CapsAdd - Add capabilities / permissions.  Returns the new effective capabilities.
@@ -180,10 +465,11 @@ func (aa *AdminAPI) SubUserRm(ctx context.Context, surm *SubUserRmRequest) error
 // this with metadata set to read will have no effect.  On the other hand, if a user's
 // permission was read, and CapsAdd was called with write, the new effective permission
 // would be read + write (*).  To remove permissions, you must call CapsRm(), which is
-// subtractive.
+// subtractive.  CapsSet does whichever is needed to leave the user with exactly
+// the caps given.
 func (aa *AdminAPI) CapsAdd(ctx context.Context, ucr *UserCapsRequest) ([]UserCap, error) {
 	resp := []UserCap{}
-	err := aa.Put(ctx, "/user?caps", ucr, nil, &resp)
+	err := aa.put(ctx, "CapsAdd", "/user?caps", ucr, &resp)
 	return resp, err
 }
 
@@ -191,6 +477,6 @@ func (aa *AdminAPI) CapsAdd(ctx context.Context, ucr *UserCapsRequest) ([]UserCa
 // See notes for CapsAdd().
 func (aa *AdminAPI) CapsRm(ctx context.Context, ucr *UserCapsRequest) ([]UserCap, error) {
 	resp := []UserCap{}
-	err := aa.Delete(ctx, "/user?caps", ucr, &resp)
+	err := aa.delete(ctx, "CapsRm", "/user?caps", ucr, &resp)
 	return resp, err
 }
@@ -0,0 +1,145 @@
+package radosgwadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UserLookupSuite struct {
+	suite.Suite
+	ctx       context.Context
+	gw        *httptest.Server
+	aa        *AdminAPI
+	byEmail   bool  // gateway supports email lookups
+	inFlight  int32 // metadata requests
+	maxFlight int32
+	metaGets  int32
+}
+
+func (us *UserLookupSuite) SetupTest() {
+	us.ctx = context.Background()
+	us.byEmail = false
+	us.inFlight, us.maxFlight, us.metaGets = 0, 0, 0
+	user, err := ioutil.ReadFile(filepath.Join("testdata", "user.json"))
+	us.Require().NoError(err)
+	notFound := func(w http.ResponseWriter, code string) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"Code":"%s"}`, code)
+	}
+	us.gw = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/admin/user":
+			switch {
+			case q.Get("uid") == "testuser", q.Get("access-key") == "werqwerqwerqwerqwerwer":
+				_, _ = w.Write(user)
+			case q.Get("email") != "" && !us.byEmail:
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"Code":"InvalidArgument"}`)
+			case q.Get("email") == "testuser@ena.com":
+				_, _ = w.Write(user)
+			default:
+				notFound(w, "NoSuchUser")
+			}
+		case "/admin/metadata/user":
+			key := q.Get("key")
+			if key == "" {
+				fmt.Fprint(w, `{"keys":["u0","u1","u2","u3","u4","u5","u6","u7","u8","u9","testuser","gone"],"truncated":false,"count":12}`)
+				return
+			}
+			atomic.AddInt32(&us.metaGets, 1)
+			n := atomic.AddInt32(&us.inFlight, 1)
+			defer atomic.AddInt32(&us.inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&us.maxFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&us.maxFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			email := key + "@example.com"
+			switch key {
+			case "gone":
+				notFound(w, "NoSuchKey")
+				return
+			case "testuser":
+				email = "TestUser@ENA.com"
+			}
+			fmt.Fprintf(w, `{"key":"user:%s","data":{"user_id":"%s","email":"%s"}}`, key, key, email)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	us.aa, err = NewAdminAPI(&Config{
+		ServerURL:       us.gw.URL,
+		AdminPath:       "admin",
+		AccessKeyID:     "a",
+		SecretAccessKey: "b",
+		ScanConcurrency: 3,
+	})
+	us.Require().NoError(err)
+}
+
+func (us *UserLookupSuite) TearDownTest() {
+	us.gw.Close()
+}
+
+func (us *UserLookupSuite) TestByAccessKey() {
+	uir, err := us.aa.UserInfoByAccessKey(us.ctx, "werqwerqwerqwerqwerwer", false)
+	us.Require().NoError(err)
+	us.Equal("testuser", uir.UserID)
+
+	_, err = us.aa.UserInfoByAccessKey(us.ctx, "nope", false)
+	us.True(errors.Is(err, ErrNoSuchUser), "got %v", err)
+	_, err = us.aa.UserInfoByAccessKey(us.ctx, "", false)
+	var ve *ValidationError
+	us.True(errors.As(err, &ve), "got %v", err)
+}
+
+func (us *UserLookupSuite) TestByEmail() {
+	us.byEmail = true
+	uir, err := us.aa.UserInfoByEmail(us.ctx, "testuser@ena.com", false)
+	us.Require().NoError(err)
+	us.Equal("testuser", uir.UserID)
+	us.Equal(int32(0), us.metaGets, "should not scan when the gateway can look up by email")
+
+	_, err = us.aa.UserInfoByEmail(us.ctx, "nobody@ena.com", false)
+	us.True(errors.Is(err, ErrNoSuchUser), "got %v", err)
+}
+
+func (us *UserLookupSuite) TestByEmailScan() {
+	direct, err := us.aa.UserInfo(us.ctx, UserID{ID: "testuser"}, false)
+	us.Require().NoError(err)
+
+	uir, err := us.aa.UserInfoByEmail(us.ctx, "testuser@ena.com", false)
+	us.Require().NoError(err)
+	us.Equal(direct, uir)
+	us.LessOrEqual(atomic.LoadInt32(&us.maxFlight), int32(3))
+
+	us.metaGets = 0
+	_, err = us.aa.UserInfoByEmail(us.ctx, "nobody@ena.com", false)
+	us.True(errors.Is(err, ErrNoSuchUser), "got %v", err)
+	us.Equal(int32(12), atomic.LoadInt32(&us.metaGets))
+	us.Equal(int32(3), atomic.LoadInt32(&us.maxFlight))
+}
+
+func (us *UserLookupSuite) TestByEmailScanCanceled() {
+	ctx, cancel := context.WithCancel(us.ctx)
+	cancel()
+	_, err := us.aa.UserInfoByEmail(ctx, "nobody@ena.com", false)
+	us.True(errors.Is(err, context.Canceled), "got %v", err)
+}
+
+func TestUserLookup(t *testing.T) {
+	suite.Run(t, new(UserLookupSuite))
+}
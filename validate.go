@@ -0,0 +1,143 @@
+package radosgwadmin
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validate - shared validator, it caches struct metadata and is safe for
+// concurrent use.  UserID and BucketRef are checked in their string form, so
+// that required works on them.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterCustomTypeFunc(func(f reflect.Value) interface{} {
+		switch id := f.Interface().(type) {
+		case UserID:
+			return id.String()
+		case BucketRef:
+			return id.String()
+		}
+		return nil
+	}, UserID{}, BucketRef{})
+	return v
+}
+
+// FieldError - one field of a request that failed validation.
+type FieldError struct {
+	// Field - path to the field from the request struct, e.g. "UserCaps[1].Type".
+	Field string
+	// Rule - the validate tag that failed, e.g. "required" or "email", or
+	// "capperm" for a cap permission that ParseCapPerm rejects.
+	Rule string
+	// Param - the rule's parameter, if any.  Alternations, like "eq=a|eq=b", are
+	// all in Rule.
+	Param string
+	// Value - the value that failed.
+	Value interface{}
+}
+
+// String - implements fmt.Stringer
+func (fe FieldError) String() string {
+	rule := fe.Rule
+	if fe.Param != "" {
+		rule += "=" + fe.Param
+	}
+	return fmt.Sprintf("%s: failed %q (value %#v)", fe.Field, rule, fe.Value)
+}
+
+// ValidationError - returned, before anything is sent to the gateway, for a
+// request that fails the rules in its validate tags.
+type ValidationError struct {
+	// Operation - name of the AdminAPI method, e.g. "UserCreate".
+	Operation string
+	// Request - type name of the request struct.
+	Request string
+	Fields  []FieldError
+}
+
+// Error - implements error
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "radosgwadmin: %s: invalid %s", e.Operation, e.Request)
+	for i, fe := range e.Fields {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(fe.String())
+	}
+	return b.String()
+}
+
+// validateRequest - check a request struct against its validate tags.  Anything
+// other than a struct, or a pointer to one, passes.
+func validateRequest(op string, req interface{}) error {
+	v := reflect.ValueOf(req)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	err := validate.Struct(req)
+	var verrs validator.ValidationErrors
+	if err != nil && !errors.As(err, &verrs) {
+		return err
+	}
+	ve := &ValidationError{Operation: op, Request: v.Type().Name()}
+	for _, fe := range verrs {
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		rule, param := fe.Tag(), fe.Param()
+		if strings.Contains(rule, "=") {
+			param = ""
+		}
+		ve.Fields = append(ve.Fields, FieldError{
+			Field: field,
+			Rule:  rule,
+			Param: param,
+			Value: fe.Value(),
+		})
+	}
+	ve.Fields = append(ve.Fields, capPermErrors(v)...)
+	if len(ve.Fields) == 0 {
+		return nil
+	}
+	return ve
+}
+
+// capPermErrors - the request's UserCaps with a permission ParseCapPerm
+// rejects.  A validate tag can't list every way of writing a permission.
+func capPermErrors(v reflect.Value) []FieldError {
+	f := v.FieldByName("UserCaps")
+	if !f.IsValid() {
+		return nil
+	}
+	caps, _ := f.Interface().([]UserCap)
+	var errs []FieldError
+	for i, c := range caps {
+		if c.Permission == "" {
+			continue // required covers this
+		}
+		if _, err := ParseCapPerm(c.Permission); err != nil {
+			errs = append(errs, FieldError{
+				Field: fmt.Sprintf("UserCaps[%d].Permission", i),
+				Rule:  "capperm",
+				Value: c.Permission,
+			})
+		}
+	}
+	return errs
+}
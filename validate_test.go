@@ -0,0 +1,104 @@
+package radosgwadmin
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidateSuite struct {
+	suite.Suite
+	ctx context.Context
+	gw  *fakeGateway
+	aa  *AdminAPI
+}
+
+func (vs *ValidateSuite) SetupTest() {
+	vs.ctx = context.Background()
+	vs.gw = newFakeGateway(http.StatusOK)
+	var err error
+	vs.aa, err = NewAdminAPI(&Config{
+		ServerURL:       vs.gw.URL,
+		AdminPath:       "admin",
+		AccessKeyID:     "a",
+		SecretAccessKey: "b",
+	})
+	vs.Require().NoError(err)
+}
+
+func (vs *ValidateSuite) TearDownTest() {
+	vs.gw.Close()
+}
+
+func (vs *ValidateSuite) TestPreflight() {
+	_, err := vs.aa.UserCreate(vs.ctx, &UserCreateRequest{
+		UID:         UserID{ID: "someone"},
+		DisplayName: "Some One",
+		Email:       "not an email",
+		KeyType:     "ftp",
+	})
+	var ve *ValidationError
+	vs.Require().True(errors.As(err, &ve), "expected a *ValidationError, got %v", err)
+	vs.Equal("UserCreate", ve.Operation)
+	vs.Equal("UserCreateRequest", ve.Request)
+	vs.Equal([]FieldError{
+		{Field: "Email", Rule: "email", Value: "not an email"},
+		{Field: "KeyType", Rule: "eq=swift|eq=s3", Value: "ftp"},
+	}, ve.Fields)
+	vs.Equal(`radosgwadmin: UserCreate: invalid UserCreateRequest: Email: failed "email" (value "not an email"); KeyType: failed "eq=swift|eq=s3" (value "ftp")`, err.Error())
+
+	// Rules on the bucket requests are enforced too.
+	_, err = vs.aa.BucketIndex(vs.ctx, &BucketIndexRequest{})
+	vs.Require().True(errors.As(err, &ve), "expected a *ValidationError, got %v", err)
+	vs.Equal([]FieldError{{Field: "Bucket", Rule: "required", Value: ""}}, ve.Fields)
+	vs.Error(vs.aa.BucketObjectRm(vs.ctx, BucketRef{Name: "bucket"}, ""))
+	vs.Error(vs.aa.BucketLink(vs.ctx, BucketRef{Name: "bucket"}, "", UserID{ID: "someone"}))
+
+	_, err = vs.aa.KeyCreate(vs.ctx, &KeyCreateRequest{UID: UserID{ID: "someone"}, KeyType: "s4"})
+	vs.True(errors.As(err, &ve))
+
+	vs.Equal(0, vs.gw.count(), "invalid requests must not be sent")
+
+	_, err = vs.aa.UserInfo(vs.ctx, UserID{ID: "someone"}, false)
+	vs.NoError(err)
+	vs.Equal(1, vs.gw.count())
+}
+
+// knownTagKeys - the struct tag keys used in this package.  Anything else is
+// most likely a typo, like validation: for validate:, that would be silently
+// ignored.
+var knownTagKeys = map[string]bool{"json": true, "url": true, "validate": true, "toml": true, "yaml": true}
+
+var tagKey = regexp.MustCompile(`(?:^|\s)([^\s:"]+):"`)
+
+func (vs *ValidateSuite) TestTagKeys() {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	vs.Require().NoError(err)
+	for _, pkg := range pkgs {
+		ast.Inspect(pkg, func(n ast.Node) bool {
+			f, ok := n.(*ast.Field)
+			if !ok || f.Tag == nil {
+				return true
+			}
+			tag, err := strconv.Unquote(f.Tag.Value)
+			vs.Require().NoError(err)
+			for _, m := range tagKey.FindAllStringSubmatch(tag, -1) {
+				vs.True(knownTagKeys[m[1]], "%s: unknown struct tag key %q", fset.Position(f.Pos()), m[1])
+			}
+			return true
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	suite.Run(t, new(ValidateSuite))
+}